@@ -2,7 +2,9 @@ package secretsengine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -13,10 +15,11 @@ const (
 )
 
 type apigeeToken struct {
-	OrgName        string `json:"org_name"`
-	DeveloperEmail string `json:"developer_email"`
-	AppName        string `json:"app_name"`
-	ApiProducts    string `json:"api_products"`
+	OrgName        string   `json:"org_name"`
+	DeveloperEmail string   `json:"developer_email"`
+	AppName        string   `json:"app_name"`
+	ApiProducts    []string `json:"api_products"`
+	Scopes         []string `json:"scopes"`
 
 	Key         string `json:"key"`
 	Secret      string `json:"secret"`
@@ -40,9 +43,13 @@ func (b *apigeeBackend) apigeeToken() *framework.Secret {
 				Description: "Apigee AppName",
 			},
 			"api_products": {
-				Type:        framework.TypeString,
+				Type:        framework.TypeCommaStringSlice,
 				Description: "Apigee ApiProducts",
 			},
+			"scopes": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Apigee Scopes",
+			},
 			"key": {
 				Type:        framework.TypeString,
 				Description: "Apigee Key",
@@ -56,69 +63,175 @@ func (b *apigeeBackend) apigeeToken() *framework.Secret {
 				Description: "Apigee Credentials",
 			},
 		},
+		Renew:  b.credentialsRenew,
 		Revoke: b.credentialsRevoke,
 	}
 }
 
-func (b *apigeeBackend) credentialsRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	client, err := b.getClient(ctx, req.Storage)
+func (b *apigeeBackend) credentialsRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName, err := getSecretString(req.Secret, "role")
 
 	if err != nil {
-		return nil, fmt.Errorf("error getting client: %w", err)
+		return nil, err
 	}
 
-	orgName := ""
-	developerEmail := ""
-	appName := ""
-	key := ""
+	if roleName == "" {
+		return nil, errors.New("secret is missing role internal data")
+	}
 
-	orgNameRaw, ok := req.Secret.InternalData["org_name"]
+	role, err := b.getRole(ctx, req.Storage, roleName)
 
-	if ok {
-		orgName, ok = orgNameRaw.(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid value for org_name in secret internal data")
-		}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving role: %w", err)
 	}
 
-	developerEmailRaw, ok := req.Secret.InternalData["developer_email"]
+	if role == nil {
+		return nil, errors.New("error retrieving role: role is nil")
+	}
 
-	if ok {
-		developerEmail, ok = developerEmailRaw.(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid value for developer_email in secret internal data")
-		}
+	ttl, warnings, err := framework.CalculateTTL(b.System(), req.Secret.Increment, role.TTL, 0, role.MaxTTL, 0, req.Secret.IssueTime)
+
+	if err != nil {
+		return nil, err
 	}
 
-	appNameRaw, ok := req.Secret.InternalData["app_name"]
+	// The expiry of the Apigee key cannot be extended, so the lease cannot
+	// outlive it.
+	if raw, _ := getSecretString(req.Secret, "key_expiry"); raw != "" {
+		keyExpiry, err := time.Parse(time.RFC3339, raw)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid key_expiry in secret internal data: %w", err)
+		}
 
-	if ok {
-		appName, ok = appNameRaw.(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid value for app_name in secret internal data")
+		if remaining := time.Until(keyExpiry); ttl > remaining {
+			ttl = remaining
+			warnings = append(warnings, "TTL exceeds the expiry of the Apigee key, capping to it")
 		}
 	}
 
-	keyRaw, ok := req.Secret.InternalData["key"]
+	resp := &logical.Response{Secret: req.Secret}
+	resp.Secret.TTL = ttl
+
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+
+	return resp, nil
+}
+
+func (b *apigeeBackend) credentialsRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName, _ := getSecretString(req.Secret, "role")
+	orgName, _ := getSecretString(req.Secret, "org_name")
+	mode, _ := getSecretString(req.Secret, "mode")
+
+	if mode == "" {
+		mode = roleModeKey
+	}
+
+	if err := b.revokeLease(ctx, req); err != nil {
+		incrCredsMetric(metricCredsRevokeFailed, roleName, orgName, mode)
+		return nil, err
+	}
+
+	incrCredsMetric(metricCredsRevoked, roleName, orgName, mode)
+
+	return nil, nil
+}
+
+func (b *apigeeBackend) revokeLease(ctx context.Context, req *logical.Request) error {
+	connection, err := getSecretString(req.Secret, "connection")
+
+	if err != nil {
+		return err
+	}
+
+	client, err := b.getClient(ctx, req.Storage, connection)
+
+	if err != nil {
+		return fmt.Errorf("error getting client: %w", err)
+	}
+
+	token, err := getSecretToken(req.Secret)
+
+	if err != nil {
+		return err
+	}
+
+	mode, err := getSecretString(req.Secret, "mode")
+
+	if err != nil {
+		return err
+	}
+
+	revokeMode, err := getSecretString(req.Secret, "revoke_mode")
 
-	if ok {
-		key, ok = keyRaw.(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid value for key in secret internal data")
+	if err != nil {
+		return err
+	}
+
+	if revokeMode == revokeModeRevoke || revokeMode == revokeModeExpire {
+		retention := defaultRevokeRetention
+
+		if raw, _ := getSecretString(req.Secret, "revoke_retention"); raw != "" {
+			if retention, err = time.ParseDuration(raw); err != nil {
+				return fmt.Errorf("invalid revoke_retention in secret internal data: %w", err)
+			}
 		}
+
+		return b.softRevokeCredentials(ctx, req.Storage, client, connection, mode, revokeMode, retention, token)
 	}
 
-	err = deleteCredentials(ctx, client, orgName, developerEmail, appName, key)
+	err = deleteLeaseCredentials(ctx, client, mode, token)
 
 	if err != nil {
-		return nil, fmt.Errorf("error deleting credentials: %w", err)
+		return fmt.Errorf("error deleting credentials: %w", err)
 	}
 
-	return nil, nil
+	return nil
 }
 
-func createCredentials(ctx context.Context, c *apigeeClient, orgName string, developerEmail string, appName string, apiProducts string, ttl int) (*apigeeToken, error) {
-	response, err := c.CreateCredentials(orgName, developerEmail, appName, apiProducts, ttl)
+func getSecretToken(secret *logical.Secret) (*apigeeToken, error) {
+	token := &apigeeToken{}
+
+	fields := map[string]*string{
+		"org_name":        &token.OrgName,
+		"developer_email": &token.DeveloperEmail,
+		"app_name":        &token.AppName,
+		"key":             &token.Key,
+	}
+
+	for field, value := range fields {
+		v, err := getSecretString(secret, field)
+
+		if err != nil {
+			return nil, err
+		}
+
+		*value = v
+	}
+
+	return token, nil
+}
+
+func getSecretString(secret *logical.Secret, field string) (string, error) {
+	raw, ok := secret.InternalData[field]
+
+	if !ok {
+		return "", nil
+	}
+
+	value, ok := raw.(string)
+
+	if !ok {
+		return "", fmt.Errorf("invalid value for %s in secret internal data", field)
+	}
+
+	return value, nil
+}
+
+func createCredentials(ctx context.Context, c *apigeeClient, orgName string, developerEmail string, appName string, apiProducts []string, attributes map[string]string, scopes []string, ttl int) (*apigeeToken, error) {
+	response, err := c.CreateCredentials(ctx, orgName, developerEmail, appName, apiProducts, attributes, scopes, ttl)
 
 	if err != nil {
 		return nil, fmt.Errorf("error creating credentials: %w", err)
@@ -129,14 +242,31 @@ func createCredentials(ctx context.Context, c *apigeeClient, orgName string, dev
 		DeveloperEmail: developerEmail,
 		AppName:        appName,
 		ApiProducts:    apiProducts,
+		Scopes:         scopes,
 		Key:            response.Key,
 		Secret:         response.Secret,
 		Credentials:    response.Credentials,
 	}, nil
 }
 
+func updateCredentials(ctx context.Context, c *apigeeClient, token *apigeeToken, attributes map[string]string, scopes []string) error {
+	if len(attributes) == 0 && len(scopes) == 0 {
+		return nil
+	}
+
+	err := c.UpdateCredentials(ctx, token.OrgName, token.DeveloperEmail, token.AppName, token.Key, attributes, scopes)
+
+	if err != nil {
+		return fmt.Errorf("error updating credentials: %w", err)
+	}
+
+	token.Scopes = scopes
+
+	return nil
+}
+
 func deleteCredentials(ctx context.Context, c *apigeeClient, orgName string, developerEmail string, appName string, key string) error {
-	err := c.DeleteCredentials(orgName, developerEmail, appName, key)
+	err := c.DeleteCredentials(ctx, orgName, developerEmail, appName, key)
 
 	if err != nil {
 		return err
@@ -144,3 +274,71 @@ func deleteCredentials(ctx context.Context, c *apigeeClient, orgName string, dev
 
 	return nil
 }
+
+func createApp(ctx context.Context, c *apigeeClient, orgName string, developerEmail string, appName string, apiProducts []string, attributes map[string]string, scopes []string, ttl int) (*apigeeToken, error) {
+	response, err := c.CreateApp(ctx, orgName, developerEmail, appName, apiProducts, attributes, scopes, ttl)
+
+	if err != nil {
+		return nil, fmt.Errorf("error creating app: %w", err)
+	}
+
+	return &apigeeToken{
+		OrgName:        orgName,
+		DeveloperEmail: developerEmail,
+		AppName:        appName,
+		ApiProducts:    apiProducts,
+		Scopes:         scopes,
+		Key:            response.Key,
+		Secret:         response.Secret,
+		Credentials:    response.Credentials,
+	}, nil
+}
+
+func deleteApp(ctx context.Context, c *apigeeClient, orgName string, developerEmail string, appName string) error {
+	err := c.DeleteApp(ctx, orgName, developerEmail, appName)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func deleteDeveloper(ctx context.Context, c *apigeeClient, orgName string, developerEmail string, appName string) error {
+	err := c.DeleteApp(ctx, orgName, developerEmail, appName)
+
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	return c.DeleteDeveloper(ctx, orgName, developerEmail)
+}
+
+func approveCredentials(ctx context.Context, c *apigeeClient, role *apigeeRole, token *apigeeToken) error {
+	if role.ApproveKey {
+		if err := c.ApproveCredentials(ctx, token.OrgName, token.DeveloperEmail, token.AppName, token.Key); err != nil {
+			return fmt.Errorf("error approving key: %w", err)
+		}
+	}
+
+	if role.ApproveApiProducts {
+		for _, product := range token.ApiProducts {
+			if err := c.ApproveCredentialsApiProduct(ctx, token.OrgName, token.DeveloperEmail, token.AppName, token.Key, product); err != nil {
+				return fmt.Errorf("error approving api product %s: %w", product, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func deleteLeaseCredentials(ctx context.Context, c *apigeeClient, mode string, token *apigeeToken) error {
+	switch mode {
+	case roleModeApp:
+		return deleteApp(ctx, c, token.OrgName, token.DeveloperEmail, token.AppName)
+	case roleModeDeveloper:
+		return deleteDeveloper(ctx, c, token.OrgName, token.DeveloperEmail, token.AppName)
+	default:
+		return deleteCredentials(ctx, c, token.OrgName, token.DeveloperEmail, token.AppName, token.Key)
+	}
+}
@@ -2,10 +2,14 @@ package secretsengine
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -25,8 +29,14 @@ const (
 
 type apigeeBackend struct {
 	*framework.Backend
-	lock   sync.RWMutex
-	client *apigeeClient
+	lock       sync.RWMutex
+	clients    map[string]*apigeeClient
+	clientFunc func(context.Context, *apigeeConfig) (*apigeeClient, error)
+
+	statusLock sync.Mutex
+	status     map[string]*connectionStatus
+
+	staticRoleLock sync.Mutex
 }
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -40,7 +50,11 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 }
 
 func backend() *apigeeBackend {
-	var b = apigeeBackend{}
+	var b = apigeeBackend{
+		clients:    map[string]*apigeeClient{},
+		clientFunc: newClient,
+		status:     map[string]*connectionStatus{},
+	}
 
 	b.Backend = &framework.Backend{
 		Help: strings.TrimSpace(help),
@@ -48,66 +62,135 @@ func backend() *apigeeBackend {
 			LocalStorage: []string{},
 			SealWrapStorage: []string{
 				"config",
+				"config/*",
 				"roles/*",
+				"static-roles/*",
 			},
 		},
 		Paths: framework.PathAppend(
 			pathRoles(&b),
+			pathStaticRoles(&b),
 			[]*framework.Path{
 				pathConfig(&b),
+				pathConfigList(&b),
 				pathCredentials(&b),
+				pathStaticCredentials(&b),
+				pathRotateRoot(&b),
 			},
 		),
 		Secrets: []*framework.Secret{
 			b.apigeeToken(),
 		},
-		BackendType: logical.TypeLogical,
-		Invalidate:  b.invalidate,
+		BackendType:       logical.TypeLogical,
+		Invalidate:        b.invalidate,
+		PeriodicFunc:      b.periodicFunc,
+		WALRollback:       b.walRollback,
+		WALRollbackMinAge: walRollbackMinAge,
 	}
 
 	return &b
 }
 
-func (b *apigeeBackend) getClient(ctx context.Context, s logical.Storage) (*apigeeClient, error) {
+func (b *apigeeBackend) getClient(ctx context.Context, s logical.Storage, connection string) (*apigeeClient, error) {
 	b.lock.RLock()
 	unlockFunc := b.lock.RUnlock
 	defer func() { unlockFunc() }()
 
-	if b.client != nil {
-		return b.client, nil
+	if client := b.clients[connection]; client != nil && client.reusable() {
+		b.warnExpiring(connection, client)
+		return client, nil
 	}
 
 	b.lock.RUnlock()
 	b.lock.Lock()
 	unlockFunc = b.lock.Unlock
 
-	config, err := getConfig(ctx, s)
+	if client := b.clients[connection]; client != nil && client.reusable() {
+		b.warnExpiring(connection, client)
+		return client, nil
+	}
+
+	config, err := getConfig(ctx, s, connection)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if config == nil {
+		if connection != "" {
+			return nil, fmt.Errorf("connection %q not found", connection)
+		}
+
 		config = new(apigeeConfig)
 	}
 
-	b.client, err = newClient(config)
+	status := b.connectionStatus(connection)
+
+	client, err := b.clientFunc(ctx, config)
 
 	if err != nil {
+		status.record(err)
 		return nil, err
 	}
 
-	return b.client, nil
+	client.managementAPI = &instrumentedAPI{managementAPI: client.managementAPI, connection: connection, status: status}
+
+	if client.expired() {
+		delete(b.clients, connection)
+		return nil, errManagementCredentialExpired
+	}
+
+	client.builtExpiring = client.expiring()
+	b.warnExpiring(connection, client)
+
+	b.clients[connection] = client
+
+	return client, nil
+}
+
+func (b *apigeeBackend) warnExpiring(connection string, client *apigeeClient) {
+	if client.expiring() {
+		b.Logger().Warn("management credential expires soon", "connection", connection, "expires_at", client.expiresAt)
+	}
+}
+
+func (b *apigeeBackend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	replicationState := b.System().ReplicationState()
+
+	if replicationState.HasState(consts.ReplicationDRSecondary|consts.ReplicationPerformanceStandby) ||
+		(!b.System().LocalMount() && replicationState.HasState(consts.ReplicationPerformanceSecondary)) {
+		return nil
+	}
+
+	return errors.Join(
+		b.rotateStaticRoles(ctx, req.Storage),
+		b.cleanupRevokedCredentials(ctx, req.Storage),
+	)
 }
 
 func (b *apigeeBackend) invalidate(ctx context.Context, key string) {
-	if key == "config" {
-		b.reset()
+	if key == configStoragePath {
+		b.reset("")
+	} else if strings.HasPrefix(key, configStoragePath+"/") {
+		b.reset(strings.TrimPrefix(key, configStoragePath+"/"))
 	}
 }
 
-func (b *apigeeBackend) reset() {
+// clientExpiry returns the expiry of the management credential of the cached
+// client for connection, or the zero time if there is none.
+func (b *apigeeBackend) clientExpiry(connection string) time.Time {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if client := b.clients[connection]; client != nil {
+		return client.expiresAt
+	}
+
+	return time.Time{}
+}
+
+func (b *apigeeBackend) reset(connection string) {
 	b.lock.Lock()
 	defer b.lock.Unlock()
-	b.client = nil
+	delete(b.clients, connection)
 }
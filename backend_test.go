@@ -2,8 +2,8 @@ package secretsengine
 
 import (
 	"context"
-	"os"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -21,7 +21,10 @@ type testEnv struct {
 	AppName        string
 	ApiProducts    string
 
-	Keys []string
+	Keys    []string
+	Secrets []*logical.Secret
+
+	Fake *fakeApigee
 
 	Backend logical.Backend
 	Context context.Context
@@ -45,6 +48,24 @@ func getTestBackend(tb testing.TB) (*apigeeBackend, logical.Storage) {
 	return b.(*apigeeBackend), config.StorageView
 }
 
+func (e *testEnv) useFake() {
+	e.Host = "https://apigee.example.com"
+	e.OAuthToken = "token"
+
+	e.OrgName = "org"
+	e.DeveloperEmail = "developer@example.com"
+	e.AppName = "app"
+	e.ApiProducts = "product"
+
+	e.Fake = newFakeApigee()
+	e.Fake.addOrg(e.OrgName, "product")
+	e.Fake.addApp(e.OrgName, e.DeveloperEmail, e.AppName)
+
+	e.Backend.(*apigeeBackend).clientFunc = func(ctx context.Context, config *apigeeConfig) (*apigeeClient, error) {
+		return &apigeeClient{managementAPI: e.Fake}, nil
+	}
+}
+
 func (e *testEnv) CreateConfig(t *testing.T) {
 	req := &logical.Request{
 		Operation: logical.CreateOperation,
@@ -61,7 +82,7 @@ func (e *testEnv) CreateConfig(t *testing.T) {
 	resp, err := e.Backend.HandleRequest(e.Context, req)
 
 	require.Nil(t, err)
-	require.Nil(t, resp)
+	require.Equal(t, []string{verifySkippedWarning}, resp.Warnings)
 }
 
 func (e *testEnv) CreateRole(t *testing.T) {
@@ -75,7 +96,8 @@ func (e *testEnv) CreateRole(t *testing.T) {
 			"app_name":        e.AppName,
 			"api_products":    e.ApiProducts,
 
-			"ttl": 86400,
+			"ttl":     86400,
+			"max_ttl": 172800,
 		},
 	}
 
@@ -98,10 +120,10 @@ func (e *testEnv) ReadCred(t *testing.T) {
 	require.NotNil(t, resp)
 	require.NotNil(t, resp.Data)
 
-	require.Contains(t, resp.Data["org_name"], os.Getenv(envVarApigeeOrgName))
-	require.Contains(t, resp.Data["developer_email"], os.Getenv(envVarApigeeDeveloperEmail))
-	require.Contains(t, resp.Data["app_name"], os.Getenv(envVarApigeeAppName))
-	require.Contains(t, resp.Data["api_products"], os.Getenv(envVarApigeeApiProducts))
+	require.Contains(t, resp.Data["org_name"], e.OrgName)
+	require.Contains(t, resp.Data["developer_email"], e.DeveloperEmail)
+	require.Contains(t, resp.Data["app_name"], e.AppName)
+	require.Equal(t, []string(parseApiProducts([]string{e.ApiProducts})), resp.Data["api_products"])
 
 	require.NotEmpty(t, resp.Data["key"])
 	require.NotEmpty(t, resp.Data["secret"])
@@ -112,25 +134,61 @@ func (e *testEnv) ReadCred(t *testing.T) {
 	if ok {
 		e.Keys = append(e.Keys, k.(string))
 	}
+
+	if e.Fake != nil {
+		require.True(t, e.Fake.hasKey(e.OrgName, e.DeveloperEmail, e.AppName, k.(string)))
+	}
+
+	e.Secrets = append(e.Secrets, resp.Secret)
 }
 
-func (e *testEnv) DeleteCreds(t *testing.T) {
-	if len(e.Keys) == 0 {
-		t.Fatalf("expected 3 keys, got: %d", len(e.Keys))
+func (e *testEnv) RenewCred(t *testing.T) {
+	if len(e.Secrets) == 0 {
+		t.Fatal("expected a secret to renew")
+	}
+
+	secret := e.Secrets[len(e.Secrets)-1]
+	key := e.Keys[len(e.Keys)-1]
+
+	req := &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   e.Storage,
+		Secret:    secret,
 	}
 
-	for _, key := range e.Keys {
-		b := e.Backend.(*apigeeBackend)
-		client, err := b.getClient(e.Context, e.Storage)
+	resp, err := e.Backend.HandleRequest(e.Context, req)
+
+	require.Nil(t, err)
+	require.NotNil(t, resp)
+	require.NotNil(t, resp.Secret)
+	require.True(t, resp.Secret.Renewable)
+	require.NotZero(t, resp.Secret.TTL)
 
-		if err != nil {
-			t.Fatal("error getting client")
+	if e.Fake != nil {
+		expiresAt := e.Fake.getKey(e.OrgName, e.DeveloperEmail, e.AppName, key).expiresAt
+		require.False(t, expiresAt.Before(time.Now().Add(resp.Secret.TTL)))
+	}
+}
+
+func (e *testEnv) RevokeCreds(t *testing.T) {
+	if len(e.Secrets) != 3 {
+		t.Fatalf("expected 3 secrets, got: %d", len(e.Secrets))
+	}
+
+	for i, secret := range e.Secrets {
+		req := &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   e.Storage,
+			Secret:    secret,
 		}
 
-		err = client.DeleteCredentials(e.OrgName, e.DeveloperEmail, e.AppName, key)
+		resp, err := e.Backend.HandleRequest(e.Context, req)
+
+		require.Nil(t, err)
+		require.Nil(t, resp)
 
-		if err != nil {
-			t.Fatalf("error deleting credentials: %s", err)
+		if e.Fake != nil {
+			require.False(t, e.Fake.hasKey(e.OrgName, e.DeveloperEmail, e.AppName, e.Keys[i]))
 		}
 	}
 }
@@ -1,16 +1,98 @@
 package secretsengine
 
 import (
+	"bytes"
+	"context"
+	b64 "encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
 
 	apigee "github.com/bstraehle/apigee-client-go"
 )
 
+const (
+	defaultRequestTimeout = 30 * time.Second
+
+	clientRefreshWindow = 5 * time.Minute
+)
+
+var errManagementCredentialExpired = errors.New("management credential expired: write a new oauth_token to config")
+
+type managementAPI interface {
+	ListOrganizations(ctx context.Context) ([]string, error)
+	ListApiProducts(ctx context.Context, orgName string) ([]string, error)
+	CreateCredentials(ctx context.Context, orgName string, developerEmail string, appName string, apiProducts []string, attributes map[string]string, scopes []string, expiresInSeconds int) (*apigee.CreateCredentialsResponse, error)
+	UpdateCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string, attributes map[string]string, scopes []string) error
+	DeleteCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error
+	ApproveCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error
+	RevokeCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error
+	ApproveCredentialsApiProduct(ctx context.Context, orgName string, developerEmail string, appName string, key string, apiProduct string) error
+	UpdateUserPassword(ctx context.Context, username string, password string) error
+	CreateApp(ctx context.Context, orgName string, developerEmail string, appName string, apiProducts []string, attributes map[string]string, scopes []string, expiresInSeconds int) (*apigee.CreateCredentialsResponse, error)
+	DeleteApp(ctx context.Context, orgName string, developerEmail string, appName string) error
+	CreateDeveloper(ctx context.Context, orgName string, developerEmail string, firstName string, lastName string, attributes map[string]string) error
+	DeleteDeveloper(ctx context.Context, orgName string, developerEmail string) error
+}
+
 type apigeeClient struct {
+	managementAPI
+	expiresAt time.Time
+
+	// builtExpiring is set if the client was built within the refresh
+	// window, so rebuilding it would not extend its expiry.
+	builtExpiring bool
+}
+
+type apigeeHTTPClient struct {
 	*apigee.Client
+	timeout time.Duration
+}
+
+type apigeeError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *apigeeError) Error() string {
+	return fmt.Sprintf("status: %d, body: %s", e.StatusCode, e.Body)
+}
+
+func isNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+func isForbidden(err error) bool {
+	code := statusCode(err)
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
+// isRejected reports whether the Apigee Management API answered err with a
+// client error, so that the request is known not to have been applied. A
+// request timeout is not a rejection, as Apigee may still complete it.
+func isRejected(err error) bool {
+	code := statusCode(err)
+	return code >= 400 && code < 500 && code != http.StatusRequestTimeout
+}
+
+// statusCode returns the HTTP status code of an Apigee Management API error,
+// or 0 if err did not come from an API response.
+func statusCode(err error) int {
+	var apiErr *apigeeError
+
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+
+	return 0
 }
 
-func newClient(config *apigeeConfig) (*apigeeClient, error) {
+func newClient(ctx context.Context, config *apigeeConfig) (*apigeeClient, error) {
 	if config == nil {
 		return nil, errors.New("client configuration was nil")
 	}
@@ -19,17 +101,429 @@ func newClient(config *apigeeConfig) (*apigeeClient, error) {
 		return nil, errors.New("client host was not defined")
 	}
 
-	if config.OAuthToken == "" {
+	transport, err := newTransport(config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	oauthToken := config.OAuthToken
+	expiresAt := config.OAuthTokenExpiry
+
+	if config.usesGoogleCredentials() {
+		ts, err := googleTokenSource(ctx, config, &http.Client{Transport: transport})
+
+		if err != nil {
+			return nil, err
+		}
+
+		token, err := ts.Token()
+
+		if err != nil {
+			return nil, fmt.Errorf("error minting access token: %w", err)
+		}
+
+		oauthToken = token.AccessToken
+		expiresAt = token.Expiry
+	} else if config.OAuthToken == "" {
 		if config.Username == "" || config.Password == "" {
-			return nil, errors.New("client oauth_token or username and password was not defined")
+			return nil, errors.New("client oauth_token, credentials, or username and password was not defined")
 		}
 	}
 
-	c, err := apigee.NewClient(config.Host, config.OAuthToken, config.Username, config.Password)
+	c, err := apigee.NewClient(config.Host, oauthToken, config.Username, config.Password)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Calls are bounded by the request context and request_timeout instead,
+	// so that the timeout covers retries.
+	c.HTTPClient.Timeout = 0
+	c.HTTPClient.Transport = newRetryTransport(transport, config.retryPolicy())
+
+	return &apigeeClient{
+		managementAPI: &apigeeHTTPClient{Client: c, timeout: config.requestTimeout()},
+		expiresAt:     expiresAt,
+	}, nil
+}
+
+func (c *apigeeClient) expiring() bool {
+	return !c.expiresAt.IsZero() && time.Now().Add(clientRefreshWindow).After(c.expiresAt)
+}
+
+// reusable reports whether a cached client can be used instead of being
+// rebuilt.
+func (c *apigeeClient) reusable() bool {
+	return !c.expired() && (!c.expiring() || c.builtExpiring)
+}
+
+func (c *apigeeClient) expired() bool {
+	return !c.expiresAt.IsZero() && !time.Now().Before(c.expiresAt)
+}
+
+func (c *apigeeHTTPClient) ListOrganizations(ctx context.Context) ([]string, error) {
+	var raw json.RawMessage
+
+	err := c.doRequest(ctx, http.MethodGet, "/v1/organizations", nil, &raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Apigee Edge returns a list of names, Apigee X an object of organizations.
+	var names []string
+
+	if err := json.Unmarshal(raw, &names); err == nil {
+		return names, nil
+	}
+
+	var orgs struct {
+		Organizations []struct {
+			Organization string `json:"organization"`
+		} `json:"organizations"`
+	}
+
+	if err := json.Unmarshal(raw, &orgs); err != nil {
+		return nil, fmt.Errorf("error parsing organizations: %w", err)
+	}
+
+	for _, org := range orgs.Organizations {
+		names = append(names, org.Organization)
+	}
+
+	return names, nil
+}
+
+func (c *apigeeHTTPClient) ListApiProducts(ctx context.Context, orgName string) ([]string, error) {
+	if orgName == "" {
+		return nil, fmt.Errorf("define orgName")
+	}
+
+	var raw json.RawMessage
+
+	err := c.doRequest(ctx, http.MethodGet, fmt.Sprintf("/v1/organizations/%s/apiproducts", url.PathEscape(orgName)), nil, &raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Apigee Edge returns a list of names, Apigee X an object of products.
+	var names []string
+
+	if err := json.Unmarshal(raw, &names); err == nil {
+		return names, nil
+	}
+
+	var products struct {
+		ApiProduct []struct {
+			Name string `json:"name"`
+		} `json:"apiProduct"`
+	}
+
+	if err := json.Unmarshal(raw, &products); err != nil {
+		return nil, fmt.Errorf("error parsing api products: %w", err)
+	}
+
+	for _, product := range products.ApiProduct {
+		names = append(names, product.Name)
+	}
+
+	return names, nil
+}
+
+func (c *apigeeHTTPClient) CreateCredentials(ctx context.Context, orgName string, developerEmail string, appName string, apiProducts []string, attributes map[string]string, scopes []string, expiresInSeconds int) (*apigee.CreateCredentialsResponse, error) {
+	if orgName == "" || developerEmail == "" || appName == "" || len(apiProducts) == 0 || expiresInSeconds == 0 {
+		return nil, fmt.Errorf("define orgName, developerEmail, appName, apiProducts, and expiresInSeconds")
+	}
+
+	// Updating the app with keyExpiresIn and apiProducts issues a new key, as
+	// apigee-client-go does, but with the request context.
+	body := map[string]interface{}{
+		"keyExpiresIn": fmt.Sprintf("%d", expiresInSeconds*1000),
+		"apiProducts":  apiProducts,
+	}
+
+	var app struct {
+		Credentials []apigee.CredentialsApigee `json:"credentials"`
+	}
+
+	err := c.doRequest(ctx, http.MethodPost, appPath(orgName, developerEmail, appName), body, &app)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(app.Credentials) == 0 {
+		return nil, fmt.Errorf("app %s was updated without credentials", appName)
+	}
+
+	key := app.Credentials[0].ConsumerKey
+	secret := app.Credentials[0].ConsumerSecret
+
+	response := &apigee.CreateCredentialsResponse{
+		Key:         key,
+		Secret:      secret,
+		Credentials: b64.StdEncoding.EncodeToString([]byte(key + ":" + secret)),
+	}
+
+	if len(attributes) == 0 && len(scopes) == 0 {
+		return response, nil
+	}
+
+	// The key exists once the app was updated, so it is returned with the
+	// error for the caller to delete it.
+	if err := c.UpdateCredentials(ctx, orgName, developerEmail, appName, response.Key, attributes, scopes); err != nil {
+		return response, fmt.Errorf("error updating key %s: %w", response.Key, err)
+	}
+
+	return response, nil
+}
+
+func (c *apigeeHTTPClient) UpdateCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string, attributes map[string]string, scopes []string) error {
+	if orgName == "" || developerEmail == "" || appName == "" || key == "" {
+		return fmt.Errorf("define orgName, developerEmail, appName, and key")
+	}
+
+	update := map[string]interface{}{}
+
+	if len(attributes) > 0 {
+		update["attributes"] = toAttributes(attributes)
+	}
+
+	if len(scopes) > 0 {
+		update["scopes"] = scopes
+	}
+
+	return c.doIdempotentRequest(ctx, http.MethodPost, appKeyPath(orgName, developerEmail, appName, key), update, nil)
+}
+
+func (c *apigeeHTTPClient) DeleteCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error {
+	if orgName == "" || developerEmail == "" || appName == "" || key == "" {
+		return fmt.Errorf("define orgName, developerEmail, appName, and key")
+	}
+
+	return c.doRequest(ctx, http.MethodDelete, appKeyPath(orgName, developerEmail, appName, key), nil, nil)
+}
+
+func (c *apigeeHTTPClient) ApproveCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error {
+	if orgName == "" || developerEmail == "" || appName == "" || key == "" {
+		return fmt.Errorf("define orgName, developerEmail, appName, and key")
+	}
+
+	return c.doIdempotentRequest(ctx, http.MethodPost, appKeyPath(orgName, developerEmail, appName, key)+"?action=approve", nil, nil)
+}
+
+func (c *apigeeHTTPClient) RevokeCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error {
+	if orgName == "" || developerEmail == "" || appName == "" || key == "" {
+		return fmt.Errorf("define orgName, developerEmail, appName, and key")
+	}
+
+	return c.doIdempotentRequest(ctx, http.MethodPost, appKeyPath(orgName, developerEmail, appName, key)+"?action=revoke", nil, nil)
+}
+
+func (c *apigeeHTTPClient) ApproveCredentialsApiProduct(ctx context.Context, orgName string, developerEmail string, appName string, key string, apiProduct string) error {
+	if orgName == "" || developerEmail == "" || appName == "" || key == "" || apiProduct == "" {
+		return fmt.Errorf("define orgName, developerEmail, appName, key, and apiProduct")
+	}
+
+	return c.doIdempotentRequest(ctx, http.MethodPost, appKeyPath(orgName, developerEmail, appName, key)+"/apiproducts/"+url.PathEscape(apiProduct)+"?action=approve", nil, nil)
+}
+
+func (c *apigeeHTTPClient) UpdateUserPassword(ctx context.Context, username string, password string) error {
+	if username == "" || password == "" {
+		return fmt.Errorf("define username and password")
+	}
+
+	body := map[string]interface{}{
+		"emailId":  username,
+		"password": password,
+	}
+
+	return c.doIdempotentRequest(ctx, http.MethodPost, "/v1/users/"+url.PathEscape(username), body, nil)
+}
+
+func (c *apigeeHTTPClient) CreateApp(ctx context.Context, orgName string, developerEmail string, appName string, apiProducts []string, attributes map[string]string, scopes []string, expiresInSeconds int) (*apigee.CreateCredentialsResponse, error) {
+	if orgName == "" || developerEmail == "" || appName == "" || len(apiProducts) == 0 || expiresInSeconds == 0 {
+		return nil, fmt.Errorf("define orgName, developerEmail, appName, apiProducts, and expiresInSeconds")
+	}
+
+	body := map[string]interface{}{
+		"name":         appName,
+		"apiProducts":  apiProducts,
+		"keyExpiresIn": fmt.Sprintf("%d", expiresInSeconds*1000),
+		"attributes":   toAttributes(attributes),
+	}
+
+	if len(scopes) > 0 {
+		body["scopes"] = scopes
+	}
+
+	var app struct {
+		Credentials []apigee.CredentialsApigee `json:"credentials"`
+	}
+
+	err := c.doRequest(ctx, http.MethodPost, developerPath(orgName, developerEmail)+"/apps", body, &app)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &apigeeClient{c}, nil
+	if len(app.Credentials) == 0 {
+		return nil, fmt.Errorf("app %s was created without credentials", appName)
+	}
+
+	key := app.Credentials[0].ConsumerKey
+	secret := app.Credentials[0].ConsumerSecret
+
+	return &apigee.CreateCredentialsResponse{
+		Key:         key,
+		Secret:      secret,
+		Credentials: b64.StdEncoding.EncodeToString([]byte(key + ":" + secret)),
+	}, nil
+}
+
+func (c *apigeeHTTPClient) DeleteApp(ctx context.Context, orgName string, developerEmail string, appName string) error {
+	if orgName == "" || developerEmail == "" || appName == "" {
+		return fmt.Errorf("define orgName, developerEmail, and appName")
+	}
+
+	return c.doRequest(ctx, http.MethodDelete, appPath(orgName, developerEmail, appName), nil, nil)
+}
+
+func (c *apigeeHTTPClient) CreateDeveloper(ctx context.Context, orgName string, developerEmail string, firstName string, lastName string, attributes map[string]string) error {
+	if orgName == "" || developerEmail == "" || firstName == "" || lastName == "" {
+		return fmt.Errorf("define orgName, developerEmail, firstName, and lastName")
+	}
+
+	body := map[string]interface{}{
+		"email":      developerEmail,
+		"userName":   developerEmail,
+		"firstName":  firstName,
+		"lastName":   lastName,
+		"attributes": toAttributes(attributes),
+	}
+
+	return c.doRequest(ctx, http.MethodPost, fmt.Sprintf("/v1/organizations/%s/developers", url.PathEscape(orgName)), body, nil)
+}
+
+func (c *apigeeHTTPClient) DeleteDeveloper(ctx context.Context, orgName string, developerEmail string) error {
+	if orgName == "" || developerEmail == "" {
+		return fmt.Errorf("define orgName and developerEmail")
+	}
+
+	return c.doRequest(ctx, http.MethodDelete, developerPath(orgName, developerEmail), nil, nil)
+}
+
+func (c *apigeeHTTPClient) doRequest(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	return c.send(ctx, method, path, in, out)
+}
+
+// doIdempotentRequest is doRequest for requests that are safe to retry even
+// though their method is not idempotent.
+func (c *apigeeHTTPClient) doIdempotentRequest(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	return c.send(withIdempotent(ctx), method, path, in, out)
+}
+
+func (c *apigeeHTTPClient) send(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var body io.Reader
+
+	if in != nil {
+		data, err := json.Marshal(in)
+
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.Host+path, body)
+
+	if err != nil {
+		return err
+	}
+
+	if c.Username != "" && c.Password != "" {
+		creds := b64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password))
+		req.Header.Set("Authorization", "Basic "+creds)
+	}
+
+	if c.OAuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.OAuthToken)
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	} else if method == http.MethodPost {
+		// Actions such as ?action=approve take no body and are rejected by
+		// Apigee unless sent as octet-stream.
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	res, err := c.HTTPClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode >= 400 {
+		apiErr := &apigeeError{StatusCode: res.StatusCode, Body: string(data)}
+
+		// Apigee rejects an expired or revoked access token with 401.
+		if res.StatusCode == http.StatusUnauthorized && c.OAuthToken != "" {
+			return fmt.Errorf("%w: %w", errManagementCredentialExpired, apiErr)
+		}
+
+		return apiErr
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+type apigeeAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func toAttributes(attributes map[string]string) []apigeeAttribute {
+	result := []apigeeAttribute{}
+
+	for name, value := range attributes {
+		result = append(result, apigeeAttribute{Name: name, Value: value})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+}
+
+func developerPath(orgName string, developerEmail string) string {
+	return fmt.Sprintf("/v1/organizations/%s/developers/%s", url.PathEscape(orgName), url.PathEscape(developerEmail))
+}
+
+func appPath(orgName string, developerEmail string, appName string) string {
+	return developerPath(orgName, developerEmail) + "/apps/" + url.PathEscape(appName)
+}
+
+func appKeyPath(orgName string, developerEmail string, appName string, key string) string {
+	return appPath(orgName, developerEmail, appName) + "/keys/" + url.PathEscape(key)
 }
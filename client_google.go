@@ -0,0 +1,111 @@
+package secretsengine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+const (
+	googleCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+var googleTokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// googleTokenSource returns a token source for the Google credentials of
+// config. Tokens are minted through httpClient, so that they use the TLS and
+// proxy settings of the connection.
+func googleTokenSource(ctx context.Context, config *apigeeConfig, httpClient *http.Client) (oauth2.TokenSource, error) {
+	var ts oauth2.TokenSource
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+
+	if config.Credentials != "" {
+		var key struct {
+			Type string `json:"type"`
+		}
+
+		if err := json.Unmarshal([]byte(config.Credentials), &key); err != nil {
+			return nil, fmt.Errorf("error parsing credentials: %w", err)
+		}
+
+		credType := google.CredentialsType(key.Type)
+
+		if credType != google.ServiceAccount && credType != google.ExternalAccount {
+			return nil, fmt.Errorf("unsupported credentials type %q", key.Type)
+		}
+
+		creds, err := google.CredentialsFromJSONWithType(ctx, []byte(config.Credentials), credType, googleCloudPlatformScope)
+
+		if err != nil {
+			return nil, fmt.Errorf("error loading credentials: %w", err)
+		}
+
+		ts = creds.TokenSource
+	} else {
+		creds, err := google.FindDefaultCredentials(ctx, googleCloudPlatformScope)
+
+		if err != nil {
+			return nil, fmt.Errorf("error finding default credentials: %w", err)
+		}
+
+		ts = creds.TokenSource
+	}
+
+	if config.ImpersonateServiceAccount == "" {
+		return ts, nil
+	}
+
+	// An HTTP client given to impersonate is used as is, so it carries the
+	// base credentials itself.
+	return impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: config.ImpersonateServiceAccount,
+		Scopes:          []string{googleCloudPlatformScope},
+		Delegates:       config.ImpersonateDelegates,
+	}, option.WithHTTPClient(oauth2.NewClient(ctx, ts)))
+}
+
+func googleTokenExpiry(ctx context.Context, httpClient *http.Client, token string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleTokenInfoURL+"?access_token="+url.QueryEscape(token), nil)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	res, err := httpClient.Do(req)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("token introspection returned status %d", res.StatusCode)
+	}
+
+	var info struct {
+		Exp string `json:"exp"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return time.Time{}, fmt.Errorf("error parsing token introspection response: %w", err)
+	}
+
+	exp, err := strconv.ParseInt(info.Exp, 10, 64)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid exp in token introspection response: %w", err)
+	}
+
+	return time.Unix(exp, 0), nil
+}
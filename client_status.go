@@ -0,0 +1,162 @@
+package secretsengine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	apigee "github.com/bstraehle/apigee-client-go"
+)
+
+// connectionStatus records the outcome of the most recent Apigee Management
+// API calls made for a connection, so that config reads can report it.
+type connectionStatus struct {
+	lock          sync.Mutex
+	lastSuccess   time.Time
+	lastError     string
+	lastErrorTime time.Time
+}
+
+func (s *connectionStatus) record(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err == nil {
+		s.lastSuccess = time.Now()
+		return
+	}
+
+	s.lastError = err.Error()
+	s.lastErrorTime = time.Now()
+}
+
+func (s *connectionStatus) snapshot() (lastSuccess time.Time, lastError string, lastErrorTime time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.lastSuccess, s.lastError, s.lastErrorTime
+}
+
+func (b *apigeeBackend) connectionStatus(connection string) *connectionStatus {
+	b.statusLock.Lock()
+	defer b.statusLock.Unlock()
+
+	status, ok := b.status[connection]
+
+	if !ok {
+		status = &connectionStatus{}
+		b.status[connection] = status
+	}
+
+	return status
+}
+
+func (b *apigeeBackend) clearConnectionStatus(connection string) {
+	b.statusLock.Lock()
+	defer b.statusLock.Unlock()
+	delete(b.status, connection)
+}
+
+// instrumentedAPI wraps a managementAPI, records the outcome of each call, and
+// emits its metrics.
+type instrumentedAPI struct {
+	managementAPI
+	connection string
+	status     *connectionStatus
+}
+
+func (a *instrumentedAPI) observe(operation string, orgName string, start time.Time, err error) {
+	a.status.record(err)
+	measureApiCall(a.connection, operation, orgName, start, err)
+}
+
+func (a *instrumentedAPI) ListOrganizations(ctx context.Context) ([]string, error) {
+	start := time.Now()
+	orgs, err := a.managementAPI.ListOrganizations(ctx)
+	a.observe("list_organizations", "", start, err)
+	return orgs, err
+}
+
+func (a *instrumentedAPI) ListApiProducts(ctx context.Context, orgName string) ([]string, error) {
+	start := time.Now()
+	products, err := a.managementAPI.ListApiProducts(ctx, orgName)
+	a.observe("list_api_products", orgName, start, err)
+	return products, err
+}
+
+func (a *instrumentedAPI) CreateCredentials(ctx context.Context, orgName string, developerEmail string, appName string, apiProducts []string, attributes map[string]string, scopes []string, expiresInSeconds int) (*apigee.CreateCredentialsResponse, error) {
+	start := time.Now()
+	resp, err := a.managementAPI.CreateCredentials(ctx, orgName, developerEmail, appName, apiProducts, attributes, scopes, expiresInSeconds)
+	a.observe("create_credentials", orgName, start, err)
+	return resp, err
+}
+
+func (a *instrumentedAPI) UpdateCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string, attributes map[string]string, scopes []string) error {
+	start := time.Now()
+	err := a.managementAPI.UpdateCredentials(ctx, orgName, developerEmail, appName, key, attributes, scopes)
+	a.observe("update_credentials", orgName, start, err)
+	return err
+}
+
+func (a *instrumentedAPI) DeleteCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error {
+	start := time.Now()
+	err := a.managementAPI.DeleteCredentials(ctx, orgName, developerEmail, appName, key)
+	a.observe("delete_credentials", orgName, start, err)
+	return err
+}
+
+func (a *instrumentedAPI) ApproveCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error {
+	start := time.Now()
+	err := a.managementAPI.ApproveCredentials(ctx, orgName, developerEmail, appName, key)
+	a.observe("approve_credentials", orgName, start, err)
+	return err
+}
+
+func (a *instrumentedAPI) RevokeCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error {
+	start := time.Now()
+	err := a.managementAPI.RevokeCredentials(ctx, orgName, developerEmail, appName, key)
+	a.observe("revoke_credentials", orgName, start, err)
+	return err
+}
+
+func (a *instrumentedAPI) ApproveCredentialsApiProduct(ctx context.Context, orgName string, developerEmail string, appName string, key string, apiProduct string) error {
+	start := time.Now()
+	err := a.managementAPI.ApproveCredentialsApiProduct(ctx, orgName, developerEmail, appName, key, apiProduct)
+	a.observe("approve_credentials_api_product", orgName, start, err)
+	return err
+}
+
+func (a *instrumentedAPI) UpdateUserPassword(ctx context.Context, username string, password string) error {
+	start := time.Now()
+	err := a.managementAPI.UpdateUserPassword(ctx, username, password)
+	a.observe("update_user_password", "", start, err)
+	return err
+}
+
+func (a *instrumentedAPI) CreateApp(ctx context.Context, orgName string, developerEmail string, appName string, apiProducts []string, attributes map[string]string, scopes []string, expiresInSeconds int) (*apigee.CreateCredentialsResponse, error) {
+	start := time.Now()
+	resp, err := a.managementAPI.CreateApp(ctx, orgName, developerEmail, appName, apiProducts, attributes, scopes, expiresInSeconds)
+	a.observe("create_app", orgName, start, err)
+	return resp, err
+}
+
+func (a *instrumentedAPI) DeleteApp(ctx context.Context, orgName string, developerEmail string, appName string) error {
+	start := time.Now()
+	err := a.managementAPI.DeleteApp(ctx, orgName, developerEmail, appName)
+	a.observe("delete_app", orgName, start, err)
+	return err
+}
+
+func (a *instrumentedAPI) CreateDeveloper(ctx context.Context, orgName string, developerEmail string, firstName string, lastName string, attributes map[string]string) error {
+	start := time.Now()
+	err := a.managementAPI.CreateDeveloper(ctx, orgName, developerEmail, firstName, lastName, attributes)
+	a.observe("create_developer", orgName, start, err)
+	return err
+}
+
+func (a *instrumentedAPI) DeleteDeveloper(ctx context.Context, orgName string, developerEmail string) error {
+	start := time.Now()
+	err := a.managementAPI.DeleteDeveloper(ctx, orgName, developerEmail)
+	a.observe("delete_developer", orgName, start, err)
+	return err
+}
@@ -0,0 +1,135 @@
+package secretsengine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientContext(t *testing.T) {
+	release := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	newTestClient := func(t *testing.T, config *apigeeConfig) *apigeeClient {
+		config.Host = srv.URL
+		config.OAuthToken = "token"
+
+		client, err := newClient(context.Background(), config)
+
+		require.NoError(t, err)
+
+		return client
+	}
+
+	t.Run("RequestTimeout", func(t *testing.T) {
+		client := newTestClient(t, &apigeeConfig{RequestTimeout: 50 * time.Millisecond})
+
+		start := time.Now()
+		err := client.DeleteCredentials(context.Background(), "org", "dev@example.com", "app", "key")
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		client := newTestClient(t, &apigeeConfig{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := client.CreateCredentials(ctx, "org", "dev@example.com", "app", []string{"product"}, nil, nil, 60)
+
+		require.ErrorIs(t, err, context.Canceled)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("CancelledDuringRetry", func(t *testing.T) {
+		unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer unavailable.Close()
+
+		client, err := newClient(context.Background(), &apigeeConfig{
+			Host:             unavailable.URL,
+			OAuthToken:       "token",
+			RetryMaxAttempts: 3,
+			RetryBaseDelay:   time.Minute,
+			RetryMaxDelay:    time.Minute,
+		})
+
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err = client.ListApiProducts(ctx, "org")
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestClientCreateCredentialsUpdateFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/organizations/org/developers/dev@example.com/apps/app" {
+			w.Write([]byte(`{"credentials": [{"consumerKey": "key", "consumerSecret": "secret"}]}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client, err := newClient(context.Background(), &apigeeConfig{Host: srv.URL, OAuthToken: "token", RetryMaxAttempts: 1})
+
+	require.NoError(t, err)
+
+	resp, err := client.CreateCredentials(context.Background(), "org", "dev@example.com", "app", []string{"product"}, map[string]string{"team": "payments"}, nil, 60)
+
+	require.Error(t, err)
+	require.Equal(t, http.StatusInternalServerError, statusCode(err))
+	require.NotNil(t, resp)
+	require.Equal(t, "key", resp.Key)
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	t.Run("OAuthToken", func(t *testing.T) {
+		client, err := newClient(context.Background(), &apigeeConfig{Host: srv.URL, OAuthToken: "token"})
+
+		require.NoError(t, err)
+
+		_, err = client.ListOrganizations(context.Background())
+
+		require.ErrorIs(t, err, errManagementCredentialExpired)
+		require.Equal(t, http.StatusUnauthorized, statusCode(err))
+	})
+
+	t.Run("Password", func(t *testing.T) {
+		client, err := newClient(context.Background(), &apigeeConfig{Host: srv.URL, Username: "admin@example.com", Password: "password"})
+
+		require.NoError(t, err)
+
+		_, err = client.ListOrganizations(context.Background())
+
+		require.NotErrorIs(t, err, errManagementCredentialExpired)
+		require.Equal(t, http.StatusUnauthorized, statusCode(err))
+	})
+}
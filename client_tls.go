@@ -0,0 +1,102 @@
+package secretsengine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// newTransport returns the HTTP transport for the Apigee Management API,
+// configured with the TLS and proxy settings of config.
+func newTransport(config *apigeeConfig) (*http.Transport, error) {
+	tlsConfig, err := config.tlsConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	transport.TLSClientConfig = tlsConfig
+
+	if config.ProxyURL != "" {
+		proxyURL, err := parseProxyURL(config.ProxyURL)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return transport, nil
+}
+
+func (c *apigeeConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CACert != "" {
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM([]byte(c.CACert)) {
+			return nil, errors.New("error parsing ca_cert: no PEM certificates found")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		return nil, errors.New("client_cert and client_key must be set together")
+	}
+
+	if c.ClientCert != "" {
+		cert, err := tls.X509KeyPair([]byte(c.ClientCert), []byte(c.ClientKey))
+
+		if err != nil {
+			return nil, fmt.Errorf("error parsing client_cert and client_key: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(raw)
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing proxy_url: %w", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy_url scheme must be http, https, or socks5, got %q", proxyURL.Scheme)
+	}
+
+	if proxyURL.Host == "" {
+		return nil, errors.New("proxy_url must include a host")
+	}
+
+	return proxyURL, nil
+}
+
+// redactedProxyURL returns ProxyURL with any password replaced by "xxxxx".
+func (c *apigeeConfig) redactedProxyURL() string {
+	proxyURL, err := url.Parse(c.ProxyURL)
+
+	if err != nil {
+		return ""
+	}
+
+	return proxyURL.Redacted()
+}
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	apigee "github.com/bstraehle/vault-plugin-secrets-apigee"
 
 	"github.com/hashicorp/go-hclog"
+	metrics "github.com/hashicorp/go-metrics"
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/sdk/plugin"
 )
 
+// metricsSinkEnv names the environment variable holding the statsd or statsite
+// URL that metrics are sent to, e.g. statsd://127.0.0.1:8125. The plugin runs
+// in its own process and does not share Vault's telemetry sink, so without it
+// metrics are discarded.
+const metricsSinkEnv = "APIGEE_PLUGIN_METRICS_SINK"
+
 func main() {
 	apiClientMeta := &api.PluginAPIClientMeta{}
 
@@ -21,16 +29,44 @@ func main() {
 
 	tlsProviderFunc := api.VaultPluginTLSProvider(tlsConfig)
 
+	logger := hclog.New(&hclog.LoggerOptions{})
+
+	if err := configureMetrics(os.Getenv(metricsSinkEnv)); err != nil {
+		logger.Error("error configuring metrics", "error", err)
+
+		os.Exit(1)
+	}
+
 	err := plugin.Serve(&plugin.ServeOpts{
 		BackendFactoryFunc: apigee.Factory,
 		TLSProviderFunc:    tlsProviderFunc,
 	})
 
 	if err != nil {
-		logger := hclog.New(&hclog.LoggerOptions{})
-
 		logger.Error("plugin shutting down", "error", err)
 
 		os.Exit(1)
 	}
 }
+
+func configureMetrics(sinkURL string) error {
+	if sinkURL == "" {
+		return nil
+	}
+
+	sink, err := metrics.NewMetricSinkFromURL(sinkURL)
+
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %w", metricsSinkEnv, err)
+	}
+
+	// Metric names match those of Vault's own telemetry, so hostname and
+	// runtime metrics are left to Vault.
+	config := metrics.DefaultConfig("vault")
+	config.EnableHostname = false
+	config.EnableRuntimeMetrics = false
+
+	_, err = metrics.NewGlobal(config, sink)
+
+	return err
+}
@@ -0,0 +1,597 @@
+package secretsengine
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	apigee "github.com/bstraehle/apigee-client-go"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+type fakeApigee struct {
+	lock  sync.Mutex
+	orgs  map[string]*fakeOrg
+	users map[string]string
+	seq   int
+
+	// denied holds the names of methods that fail with 403 Forbidden.
+	denied map[string]bool
+
+	// unavailable holds the names of methods that take effect but then fail
+	// with 503 Service Unavailable, as if the response was lost.
+	unavailable map[string]bool
+}
+
+type fakeOrg struct {
+	products     map[string]bool
+	unapprovable map[string]bool
+	developers   map[string]*fakeDeveloper
+}
+
+type fakeDeveloper struct {
+	firstName  string
+	lastName   string
+	attributes map[string]string
+	apps       map[string]*fakeApp
+}
+
+type fakeApp struct {
+	attributes map[string]string
+	keys       map[string]*fakeKey
+}
+
+type fakeKey struct {
+	secret      string
+	apiProducts []string
+	attributes  map[string]string
+	scopes      []string
+	expiresAt   time.Time
+
+	approved         bool
+	revoked          bool
+	approvedProducts map[string]bool
+}
+
+func newFakeApigee() *fakeApigee {
+	return &fakeApigee{
+		orgs:        map[string]*fakeOrg{},
+		users:       map[string]string{},
+		denied:      map[string]bool{},
+		unavailable: map[string]bool{},
+	}
+}
+
+func getTestBackendWithFake(tb testing.TB) (*apigeeBackend, logical.Storage, *fakeApigee) {
+	tb.Helper()
+
+	b, s := getTestBackend(tb)
+	fake := newFakeApigee()
+
+	b.clientFunc = func(ctx context.Context, config *apigeeConfig) (*apigeeClient, error) {
+		return &apigeeClient{managementAPI: fake}, nil
+	}
+
+	return b, s, fake
+}
+
+func (f *fakeApigee) addOrg(orgName string, apiProducts ...string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	org := &fakeOrg{
+		products:     map[string]bool{},
+		unapprovable: map[string]bool{},
+		developers:   map[string]*fakeDeveloper{},
+	}
+
+	for _, product := range apiProducts {
+		org.products[product] = true
+	}
+
+	f.orgs[orgName] = org
+}
+
+func (f *fakeApigee) deny(method string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.denied[method] = true
+}
+
+func (f *fakeApigee) makeUnavailable(method string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.unavailable[method] = true
+}
+
+func (f *fakeApigee) setUnapprovable(orgName string, apiProduct string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.orgs[orgName].unapprovable[apiProduct] = true
+}
+
+func (f *fakeApigee) addDeveloper(orgName string, developerEmail string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.orgs[orgName].developers[developerEmail] = &fakeDeveloper{apps: map[string]*fakeApp{}}
+}
+
+func (f *fakeApigee) addApp(orgName string, developerEmail string, appName string) {
+	if !f.hasDeveloper(orgName, developerEmail) {
+		f.addDeveloper(orgName, developerEmail)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.orgs[orgName].developers[developerEmail].apps[appName] = &fakeApp{keys: map[string]*fakeKey{}}
+}
+
+func (f *fakeApigee) getDeveloper(orgName string, developerEmail string) *fakeDeveloper {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	developer, err := f.developer(orgName, developerEmail)
+
+	if err != nil {
+		return nil
+	}
+
+	return developer
+}
+
+func (f *fakeApigee) hasDeveloper(orgName string, developerEmail string) bool {
+	return f.getDeveloper(orgName, developerEmail) != nil
+}
+
+func (f *fakeApigee) developers(orgName string) []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var emails []string
+
+	for email := range f.orgs[orgName].developers {
+		emails = append(emails, email)
+	}
+
+	return emails
+}
+
+func (f *fakeApigee) apps(orgName string, developerEmail string) []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	developer, err := f.developer(orgName, developerEmail)
+
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+
+	for name := range developer.apps {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func (f *fakeApigee) keys(orgName string, developerEmail string, appName string) []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	app, err := f.app(orgName, developerEmail, appName)
+
+	if err != nil {
+		return nil
+	}
+
+	var keys []string
+
+	for key := range app.keys {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (f *fakeApigee) getKey(orgName string, developerEmail string, appName string, key string) *fakeKey {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	app, err := f.app(orgName, developerEmail, appName)
+
+	if err != nil {
+		return nil
+	}
+
+	return app.keys[key]
+}
+
+func (f *fakeApigee) hasKey(orgName string, developerEmail string, appName string, key string) bool {
+	return f.getKey(orgName, developerEmail, appName, key) != nil
+}
+
+func (f *fakeApigee) developer(orgName string, developerEmail string) (*fakeDeveloper, error) {
+	org, ok := f.orgs[orgName]
+
+	if !ok {
+		return nil, fakeNotFound("organization", orgName)
+	}
+
+	developer, ok := org.developers[developerEmail]
+
+	if !ok {
+		return nil, fakeNotFound("developer", developerEmail)
+	}
+
+	return developer, nil
+}
+
+func (f *fakeApigee) app(orgName string, developerEmail string, appName string) (*fakeApp, error) {
+	developer, err := f.developer(orgName, developerEmail)
+
+	if err != nil {
+		return nil, err
+	}
+
+	app, ok := developer.apps[appName]
+
+	if !ok {
+		return nil, fakeNotFound("app", appName)
+	}
+
+	return app, nil
+}
+
+func (f *fakeApigee) ListOrganizations(ctx context.Context) ([]string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.denied["ListOrganizations"] {
+		return nil, fakeForbidden()
+	}
+
+	var orgs []string
+
+	for org := range f.orgs {
+		orgs = append(orgs, org)
+	}
+
+	return orgs, nil
+}
+
+func (f *fakeApigee) ListApiProducts(ctx context.Context, orgName string) ([]string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	org, ok := f.orgs[orgName]
+
+	if !ok {
+		return nil, fakeNotFound("organization", orgName)
+	}
+
+	var products []string
+
+	for product := range org.products {
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+func (f *fakeApigee) key(orgName string, developerEmail string, appName string, key string) (*fakeKey, error) {
+	app, err := f.app(orgName, developerEmail, appName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	k, ok := app.keys[key]
+
+	if !ok {
+		return nil, fakeNotFound("key", key)
+	}
+
+	return k, nil
+}
+
+func (f *fakeApigee) CreateCredentials(ctx context.Context, orgName string, developerEmail string, appName string, apiProducts []string, attributes map[string]string, scopes []string, expiresInSeconds int) (*apigee.CreateCredentialsResponse, error) {
+	if orgName == "" || developerEmail == "" || appName == "" || len(apiProducts) == 0 || expiresInSeconds == 0 {
+		return nil, fmt.Errorf("define orgName, developerEmail, appName, apiProducts, and expiresInSeconds")
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.denied["CreateCredentials"] {
+		return nil, fakeForbidden()
+	}
+
+	app, err := f.app(orgName, developerEmail, appName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if (len(attributes) > 0 || len(scopes) > 0) && f.denied["UpdateCredentials"] {
+		response, err := f.addKey(orgName, app, apiProducts, nil, nil, expiresInSeconds)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return response, fakeForbidden()
+	}
+
+	response, err := f.addKey(orgName, app, apiProducts, attributes, scopes, expiresInSeconds)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if f.unavailable["CreateCredentials"] {
+		return nil, fakeUnavailable()
+	}
+
+	return response, nil
+}
+
+func (f *fakeApigee) UpdateCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string, attributes map[string]string, scopes []string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.denied["UpdateCredentials"] {
+		return fakeForbidden()
+	}
+
+	k, err := f.key(orgName, developerEmail, appName, key)
+
+	if err != nil {
+		return err
+	}
+
+	if len(attributes) > 0 {
+		k.attributes = attributes
+	}
+
+	if len(scopes) > 0 {
+		k.scopes = scopes
+	}
+
+	return nil
+}
+
+func (f *fakeApigee) addKey(orgName string, app *fakeApp, apiProducts []string, attributes map[string]string, scopes []string, expiresInSeconds int) (*apigee.CreateCredentialsResponse, error) {
+	for _, product := range apiProducts {
+		if !f.orgs[orgName].products[product] {
+			return nil, fakeNotFound("api product", product)
+		}
+	}
+
+	f.seq++
+	key := fmt.Sprintf("key-%d", f.seq)
+	secret := fmt.Sprintf("secret-%d", f.seq)
+
+	app.keys[key] = &fakeKey{
+		secret:      secret,
+		apiProducts: apiProducts,
+		attributes:  attributes,
+		scopes:      scopes,
+		expiresAt:   time.Now().Add(time.Duration(expiresInSeconds) * time.Second),
+	}
+
+	return &apigee.CreateCredentialsResponse{
+		Key:         key,
+		Secret:      secret,
+		Credentials: b64.StdEncoding.EncodeToString([]byte(key + ":" + secret)),
+	}, nil
+}
+
+func (f *fakeApigee) DeleteCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.denied["DeleteCredentials"] {
+		return fakeForbidden()
+	}
+
+	app, err := f.app(orgName, developerEmail, appName)
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := app.keys[key]; !ok {
+		return fakeNotFound("key", key)
+	}
+
+	delete(app.keys, key)
+
+	return nil
+}
+
+func (f *fakeApigee) ApproveCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	k, err := f.key(orgName, developerEmail, appName, key)
+
+	if err != nil {
+		return err
+	}
+
+	k.approved = true
+
+	return nil
+}
+
+func (f *fakeApigee) RevokeCredentials(ctx context.Context, orgName string, developerEmail string, appName string, key string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	k, err := f.key(orgName, developerEmail, appName, key)
+
+	if err != nil {
+		return err
+	}
+
+	k.revoked = true
+
+	return nil
+}
+
+func (f *fakeApigee) ApproveCredentialsApiProduct(ctx context.Context, orgName string, developerEmail string, appName string, key string, apiProduct string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	k, err := f.key(orgName, developerEmail, appName, key)
+
+	if err != nil {
+		return err
+	}
+
+	if f.orgs[orgName].unapprovable[apiProduct] {
+		return &apigeeError{StatusCode: http.StatusForbidden, Body: fmt.Sprintf("api product %s cannot be approved", apiProduct)}
+	}
+
+	if k.approvedProducts == nil {
+		k.approvedProducts = map[string]bool{}
+	}
+
+	k.approvedProducts[apiProduct] = true
+
+	return nil
+}
+
+func (f *fakeApigee) UpdateUserPassword(ctx context.Context, username string, password string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, ok := f.users[username]; !ok {
+		return fakeNotFound("user", username)
+	}
+
+	f.users[username] = password
+
+	return nil
+}
+
+func (f *fakeApigee) CreateApp(ctx context.Context, orgName string, developerEmail string, appName string, apiProducts []string, attributes map[string]string, scopes []string, expiresInSeconds int) (*apigee.CreateCredentialsResponse, error) {
+	if orgName == "" || developerEmail == "" || appName == "" || len(apiProducts) == 0 || expiresInSeconds == 0 {
+		return nil, fmt.Errorf("define orgName, developerEmail, appName, apiProducts, and expiresInSeconds")
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	developer, err := f.developer(orgName, developerEmail)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := developer.apps[appName]; ok {
+		return nil, &apigeeError{StatusCode: http.StatusConflict, Body: fmt.Sprintf("app %s already exists", appName)}
+	}
+
+	app := &fakeApp{attributes: attributes, keys: map[string]*fakeKey{}}
+
+	response, err := f.addKey(orgName, app, apiProducts, nil, scopes, expiresInSeconds)
+
+	if err != nil {
+		return nil, err
+	}
+
+	developer.apps[appName] = app
+
+	if f.unavailable["CreateApp"] {
+		return nil, fakeUnavailable()
+	}
+
+	return response, nil
+}
+
+func (f *fakeApigee) DeleteApp(ctx context.Context, orgName string, developerEmail string, appName string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	developer, err := f.developer(orgName, developerEmail)
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := developer.apps[appName]; !ok {
+		return fakeNotFound("app", appName)
+	}
+
+	delete(developer.apps, appName)
+
+	return nil
+}
+
+func (f *fakeApigee) CreateDeveloper(ctx context.Context, orgName string, developerEmail string, firstName string, lastName string, attributes map[string]string) error {
+	if orgName == "" || developerEmail == "" || firstName == "" || lastName == "" {
+		return fmt.Errorf("define orgName, developerEmail, firstName, and lastName")
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	org, ok := f.orgs[orgName]
+
+	if !ok {
+		return fakeNotFound("organization", orgName)
+	}
+
+	if _, ok := org.developers[developerEmail]; ok {
+		return &apigeeError{StatusCode: http.StatusConflict, Body: fmt.Sprintf("developer %s already exists", developerEmail)}
+	}
+
+	org.developers[developerEmail] = &fakeDeveloper{
+		firstName:  firstName,
+		lastName:   lastName,
+		attributes: attributes,
+		apps:       map[string]*fakeApp{},
+	}
+
+	if f.unavailable["CreateDeveloper"] {
+		return fakeUnavailable()
+	}
+
+	return nil
+}
+
+func (f *fakeApigee) DeleteDeveloper(ctx context.Context, orgName string, developerEmail string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, err := f.developer(orgName, developerEmail); err != nil {
+		return err
+	}
+
+	delete(f.orgs[orgName].developers, developerEmail)
+
+	return nil
+}
+
+func fakeForbidden() error {
+	return &apigeeError{StatusCode: http.StatusForbidden, Body: "permission denied"}
+}
+
+func fakeUnavailable() error {
+	return &apigeeError{StatusCode: http.StatusServiceUnavailable, Body: "service unavailable"}
+}
+
+func fakeNotFound(kind string, name string) error {
+	return &apigeeError{StatusCode: http.StatusNotFound, Body: fmt.Sprintf("%s %s not found", kind, name)}
+}
@@ -0,0 +1,59 @@
+package secretsengine
+
+import (
+	"strconv"
+	"time"
+
+	metrics "github.com/hashicorp/go-metrics"
+)
+
+var (
+	metricCredsCreated      = []string{"secrets", "apigee", "creds", "created"}
+	metricCredsCreateFailed = []string{"secrets", "apigee", "creds", "create_failed"}
+	metricCredsRevoked      = []string{"secrets", "apigee", "creds", "revoked"}
+	metricCredsRevokeFailed = []string{"secrets", "apigee", "creds", "revoke_failed"}
+	metricApiLatency        = []string{"secrets", "apigee", "api", "latency"}
+	metricApiRequests       = []string{"secrets", "apigee", "api", "requests"}
+)
+
+func credsLabels(roleName string, orgName string, mode string) []metrics.Label {
+	return []metrics.Label{
+		{Name: "role", Value: roleName},
+		{Name: "org", Value: orgName},
+		{Name: "mode", Value: mode},
+	}
+}
+
+func incrCredsMetric(key []string, roleName string, orgName string, mode string) {
+	metrics.IncrCounterWithLabels(key, 1, credsLabels(roleName, orgName, mode))
+}
+
+// measureApiCall records the latency of a management API call and counts it
+// by status code. Successful calls are counted as "2xx", as the exact status
+// is not returned by the client, and calls that failed without a response as
+// "error".
+func measureApiCall(connection string, operation string, orgName string, start time.Time, err error) {
+	if connection == "" {
+		connection = "default"
+	}
+
+	labels := []metrics.Label{
+		{Name: "connection", Value: connection},
+		{Name: "operation", Value: operation},
+		{Name: "org", Value: orgName},
+	}
+
+	metrics.MeasureSinceWithLabels(metricApiLatency, start, labels)
+
+	status := "2xx"
+
+	if err != nil {
+		status = "error"
+
+		if code := statusCode(err); code != 0 {
+			status = strconv.Itoa(code)
+		}
+	}
+
+	metrics.IncrCounterWithLabels(metricApiRequests, 1, append(labels, metrics.Label{Name: "status_code", Value: status}))
+}
@@ -0,0 +1,167 @@
+package secretsengine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metrics "github.com/hashicorp/go-metrics"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	sink := useInmemMetrics(t)
+
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	_, err := testRoleCreate(t, b, s, map[string]interface{}{
+		"org_name":        "org",
+		"developer_email": "dev@example.com",
+		"app_name":        "app",
+		"api_products":    "product",
+	})
+
+	require.NoError(t, err)
+
+	readCreds := func(t *testing.T) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	revoke := func(t *testing.T, resp *logical.Response) error {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   s,
+			Secret:    resp.Secret,
+		})
+
+		return err
+	}
+
+	t.Run("CredsCreatedAndRevoked", func(t *testing.T) {
+		resp := readCreds(t)
+
+		require.NoError(t, revoke(t, resp))
+
+		labels := map[string]string{"role": "test", "org": "org", "mode": roleModeKey}
+
+		require.Equal(t, 1, counterCount(sink, "secrets.apigee.creds.created", labels))
+		require.Equal(t, 1, counterCount(sink, "secrets.apigee.creds.revoked", labels))
+	})
+
+	t.Run("RevokeFailed", func(t *testing.T) {
+		resp := readCreds(t)
+
+		fake.deny("DeleteCredentials")
+		defer delete(fake.denied, "DeleteCredentials")
+
+		require.Error(t, revoke(t, resp))
+		require.Equal(t, 1, counterCount(sink, "secrets.apigee.creds.revoke_failed", map[string]string{"role": "test", "org": "org"}))
+	})
+
+	t.Run("CreateFailed", func(t *testing.T) {
+		fake.deny("CreateCredentials")
+		defer delete(fake.denied, "CreateCredentials")
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.Error(t, err)
+		require.Equal(t, 1, counterCount(sink, "secrets.apigee.creds.create_failed", map[string]string{"role": "test", "org": "org"}))
+	})
+
+	t.Run("ApiRequests", func(t *testing.T) {
+		require.Equal(t, 2, counterCount(sink, "secrets.apigee.api.requests", map[string]string{
+			"connection":  "default",
+			"operation":   "create_credentials",
+			"org":         "org",
+			"status_code": "2xx",
+		}))
+		require.Equal(t, 1, counterCount(sink, "secrets.apigee.api.requests", map[string]string{
+			"operation":   "delete_credentials",
+			"status_code": "403",
+		}))
+		require.Positive(t, sampleCount(sink, "secrets.apigee.api.latency", map[string]string{"operation": "create_credentials"}))
+	})
+}
+
+func useInmemMetrics(t *testing.T) *metrics.InmemSink {
+	t.Helper()
+
+	config := metrics.DefaultConfig("")
+	config.EnableHostname = false
+	config.EnableRuntimeMetrics = false
+
+	sink := metrics.NewInmemSink(time.Hour, time.Hour)
+
+	_, err := metrics.NewGlobal(config, sink)
+
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		metrics.NewGlobal(config, &metrics.BlackholeSink{})
+	})
+
+	return sink
+}
+
+// counterCount sums the counts of the counters named name that have all of
+// labels.
+func counterCount(sink *metrics.InmemSink, name string, labels map[string]string) int {
+	var count int
+
+	for _, interval := range sink.Data() {
+		for _, counter := range interval.Counters {
+			if matchesMetric(counter.Name, counter.Labels, name, labels) {
+				count += int(counter.Sum)
+			}
+		}
+	}
+
+	return count
+}
+
+func sampleCount(sink *metrics.InmemSink, name string, labels map[string]string) int {
+	var count int
+
+	for _, interval := range sink.Data() {
+		for _, sample := range interval.Samples {
+			if matchesMetric(sample.Name, sample.Labels, name, labels) {
+				count += sample.Count
+			}
+		}
+	}
+
+	return count
+}
+
+func matchesMetric(metricName string, metricLabels []metrics.Label, name string, labels map[string]string) bool {
+	if strings.TrimPrefix(metricName, ".") != name {
+		return false
+	}
+
+	matched := 0
+
+	for _, label := range metricLabels {
+		if value, ok := labels[label.Name]; ok && value == label.Value {
+			matched++
+		}
+	}
+
+	return matched == len(labels)
+}
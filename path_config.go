@@ -4,6 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"time"
+
+	apigee "github.com/bstraehle/apigee-client-go"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -11,19 +16,52 @@ import (
 
 const (
 	configStoragePath = "config"
+
+	authMethodOAuthToken    = "oauth_token"
+	authMethodCredentials   = "credentials"
+	authMethodImpersonation = "impersonation"
+	authMethodBasic         = "basic"
 )
 
 type apigeeConfig struct {
-	Host       string `json:"host"`
-	OAuthToken string `json:"oauth_token"`
-	Username   string `json:"username"`
-	Password   string `json:"password"`
+	Host             string    `json:"host"`
+	OAuthToken       string    `json:"oauth_token"`
+	OAuthTokenExpiry time.Time `json:"oauth_token_expiry"`
+	Username         string    `json:"username"`
+	Password         string    `json:"password"`
+	PasswordPolicy   string    `json:"password_policy"`
+
+	Credentials               string   `json:"credentials"`
+	ImpersonateServiceAccount string   `json:"impersonate_service_account"`
+	ImpersonateDelegates      []string `json:"impersonate_delegates"`
+
+	AllowedOrgs            []string `json:"allowed_orgs"`
+	AllowedDeveloperEmails []string `json:"allowed_developer_emails"`
+	AllowedAppNames        []string `json:"allowed_app_names"`
+
+	RetryMaxAttempts int           `json:"retry_max_attempts"`
+	RetryBaseDelay   time.Duration `json:"retry_base_delay"`
+	RetryMaxDelay    time.Duration `json:"retry_max_delay"`
+	RetryJitter      float64       `json:"retry_jitter"`
+
+	RequestTimeout time.Duration `json:"request_timeout"`
+
+	CACert             string `json:"ca_cert"`
+	ClientCert         string `json:"client_cert"`
+	ClientKey          string `json:"client_key"`
+	TLSServerName      string `json:"tls_server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	ProxyURL           string `json:"proxy_url"`
 }
 
 func pathConfig(b *apigeeBackend) *framework.Path {
 	return &framework.Path{
-		Pattern: "config",
+		Pattern: "config(/" + framework.GenericNameRegex("connection") + ")?",
 		Fields: map[string]*framework.FieldSchema{
+			"connection": {
+				Type:        framework.TypeString,
+				Description: "The name of the connection. If not set, the default connection is configured",
+			},
 			"host": {
 				Type:        framework.TypeString,
 				Description: "The host for the Apigee Management API",
@@ -42,6 +80,15 @@ func pathConfig(b *apigeeBackend) *framework.Path {
 					Sensitive: true,
 				},
 			},
+			"oauth_token_expiry": {
+				Type:        framework.TypeTime,
+				Description: "The expiry of oauth_token, as RFC3339 or seconds since epoch. If not set, the expiry of a Google access token is looked up when oauth_token is written",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "oauth_token_expiry",
+					Sensitive: false,
+				},
+			},
 			"username": {
 				Type:        framework.TypeString,
 				Description: "The username for the Apigee Management API",
@@ -60,6 +107,183 @@ func pathConfig(b *apigeeBackend) *framework.Path {
 					Sensitive: true,
 				},
 			},
+			"password_policy": {
+				Type:        framework.TypeString,
+				Description: "The password policy used to generate passwords in rotate-root. If not set, a random 24 character password with upper and lower case letters, digits, and symbols is generated",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "password_policy",
+					Sensitive: false,
+				},
+			},
+			"credentials": {
+				Type:        framework.TypeString,
+				Description: "The Google service account or external account JSON key used to mint access tokens for the Apigee Management API",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "credentials",
+					Sensitive: true,
+				},
+			},
+			"impersonate_service_account": {
+				Type:        framework.TypeString,
+				Description: "The Google service account to impersonate when minting access tokens, using credentials or the application default credentials",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "impersonate_service_account",
+					Sensitive: false,
+				},
+			},
+			"impersonate_delegates": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "The delegation chain of Google service accounts used to impersonate impersonate_service_account",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "impersonate_delegates",
+					Sensitive: false,
+				},
+			},
+			"allowed_orgs": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Orgs that roles may use, as globs. If not set, all orgs are allowed",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "allowed_orgs",
+					Sensitive: false,
+				},
+			},
+			"allowed_developer_emails": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Developer emails that roles may use, as globs. If not set, all developer emails are allowed",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "allowed_developer_emails",
+					Sensitive: false,
+				},
+			},
+			"allowed_app_names": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "App names that roles may use, as globs. If not set, all app names are allowed",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "allowed_app_names",
+					Sensitive: false,
+				},
+			},
+			"retry_max_attempts": {
+				Type:        framework.TypeInt,
+				Description: "The maximum number of attempts for a retryable Apigee Management API request. Set to 1 to disable retries",
+				Required:    false,
+				Default:     defaultRetryMaxAttempts,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "retry_max_attempts",
+					Sensitive: false,
+				},
+			},
+			"retry_base_delay": {
+				Type:        framework.TypeDurationSecond,
+				Description: "The delay before the first retry, doubled for every following retry",
+				Required:    false,
+				Default:     int(defaultRetryBaseDelay.Seconds()),
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "retry_base_delay",
+					Sensitive: false,
+				},
+			},
+			"retry_max_delay": {
+				Type:        framework.TypeDurationSecond,
+				Description: "The maximum delay between retries. Requests whose Retry-After exceeds it are not retried",
+				Required:    false,
+				Default:     int(defaultRetryMaxDelay.Seconds()),
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "retry_max_delay",
+					Sensitive: false,
+				},
+			},
+			"retry_jitter": {
+				Type:        framework.TypeFloat,
+				Description: "The fraction, between 0 and 1, by which retry delays are randomly reduced",
+				Required:    false,
+				Default:     defaultRetryJitter,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "retry_jitter",
+					Sensitive: false,
+				},
+			},
+			"request_timeout": {
+				Type:        framework.TypeDurationSecond,
+				Description: "The maximum duration of an Apigee Management API call, including its retries",
+				Required:    false,
+				Default:     int(defaultRequestTimeout.Seconds()),
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "request_timeout",
+					Sensitive: false,
+				},
+			},
+			"ca_cert": {
+				Type:        framework.TypeString,
+				Description: "PEM-encoded CA certificates used to verify the Apigee Management API server. If not set, the system roots are used",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "ca_cert",
+					Sensitive: false,
+				},
+			},
+			"client_cert": {
+				Type:        framework.TypeString,
+				Description: "PEM-encoded client certificate for mutual TLS with the Apigee Management API",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "client_cert",
+					Sensitive: false,
+				},
+			},
+			"client_key": {
+				Type:        framework.TypeString,
+				Description: "PEM-encoded private key of client_cert",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "client_key",
+					Sensitive: true,
+				},
+			},
+			"tls_server_name": {
+				Type:        framework.TypeString,
+				Description: "The server name used to verify the certificate of the Apigee Management API server, if it differs from host",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "tls_server_name",
+					Sensitive: false,
+				},
+			},
+			"insecure_skip_verify": {
+				Type:        framework.TypeBool,
+				Description: "Skip verification of the Apigee Management API server certificate. Not recommended, use ca_cert instead",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "insecure_skip_verify",
+					Sensitive: false,
+				},
+			},
+			"proxy_url": {
+				Type:        framework.TypeString,
+				Description: "The http, https, or socks5 proxy for the Apigee Management API. If not set, the proxy environment variables of the Vault server are used",
+				Required:    false,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "proxy_url",
+					Sensitive: true,
+				},
+			},
+			"verify_connection": {
+				Type:        framework.TypeBool,
+				Description: "Whether to verify the connection and the permission to create and delete developer app keys before the config is stored",
+				Required:    false,
+				Default:     true,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "verify_connection",
+					Sensitive: false,
+				},
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
@@ -81,6 +305,19 @@ func pathConfig(b *apigeeBackend) *framework.Path {
 	}
 }
 
+func pathConfigList(b *apigeeBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathConfigList,
+			},
+		},
+		HelpSynopsis:    pathConfigListHelpSynopsis,
+		HelpDescription: pathConfigListHelpDescription,
+	}
+}
+
 func (b *apigeeBackend) pathConfigExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
 	out, err := req.Storage.Get(ctx, req.Path)
 
@@ -92,21 +329,60 @@ func (b *apigeeBackend) pathConfigExistenceCheck(ctx context.Context, req *logic
 }
 
 func (b *apigeeBackend) pathConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	config, err := getConfig(ctx, req.Storage)
+	connection := data.Get("connection").(string)
+
+	config, err := getConfig(ctx, req.Storage, connection)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if config == nil {
+		return nil, nil
+	}
+
+	tokenExpiry := config.OAuthTokenExpiry
+
+	if config.usesGoogleCredentials() {
+		tokenExpiry = b.clientExpiry(connection)
+	}
+
+	lastSuccess, lastError, lastErrorTime := b.connectionStatus(connection).snapshot()
+	policy := config.retryPolicy()
+
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"host": config.Host,
+			"host":                     config.Host,
+			"auth_method":              config.authMethod(),
+			"oauth_token_set":          config.OAuthToken != "",
+			"password_set":             config.Password != "",
+			"credentials_set":          config.Credentials != "",
+			"token_expiry":             optionalTime(tokenExpiry),
+			"last_api_success":         optionalTime(lastSuccess),
+			"last_error":               lastError,
+			"last_error_time":          optionalTime(lastErrorTime),
+			"allowed_orgs":             config.AllowedOrgs,
+			"allowed_developer_emails": config.AllowedDeveloperEmails,
+			"allowed_app_names":        config.AllowedAppNames,
+			"retry_max_attempts":       policy.maxAttempts,
+			"retry_base_delay":         policy.baseDelay.Seconds(),
+			"retry_max_delay":          policy.maxDelay.Seconds(),
+			"retry_jitter":             policy.jitter,
+			"request_timeout":          config.requestTimeout().Seconds(),
+			"ca_cert":                  config.CACert,
+			"client_cert":              config.ClientCert,
+			"client_key_set":           config.ClientKey != "",
+			"tls_server_name":          config.TLSServerName,
+			"insecure_skip_verify":     config.InsecureSkipVerify,
+			"proxy_url":                config.redactedProxyURL(),
 		},
 	}, nil
 }
 
 func (b *apigeeBackend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	config, err := getConfig(ctx, req.Storage)
+	connection := data.Get("connection").(string)
+
+	config, err := getConfig(ctx, req.Storage, connection)
 
 	if err != nil {
 		return nil, err
@@ -127,8 +403,19 @@ func (b *apigeeBackend) pathConfigWrite(ctx context.Context, req *logical.Reques
 		return nil, fmt.Errorf("missing host in configuration")
 	}
 
+	lookUpTokenExpiry := false
+
 	if oauth_token, ok := data.GetOk("oauth_token"); ok {
 		config.OAuthToken = oauth_token.(string)
+		config.OAuthTokenExpiry = time.Time{}
+
+		if oauth_token_expiry, ok := data.GetOk("oauth_token_expiry"); ok {
+			config.OAuthTokenExpiry = oauth_token_expiry.(time.Time)
+		} else {
+			lookUpTokenExpiry = config.OAuthToken != "" && config.Host == apigee.Host
+		}
+	} else if oauth_token_expiry, ok := data.GetOk("oauth_token_expiry"); ok {
+		config.OAuthTokenExpiry = oauth_token_expiry.(time.Time)
 	}
 
 	if username, ok := data.GetOk("username"); ok {
@@ -139,33 +426,192 @@ func (b *apigeeBackend) pathConfigWrite(ctx context.Context, req *logical.Reques
 		config.Password = password.(string)
 	}
 
-	entry, err := logical.StorageEntryJSON(configStoragePath, config)
+	if password_policy, ok := data.GetOk("password_policy"); ok {
+		config.PasswordPolicy = password_policy.(string)
+	}
+
+	if credentials, ok := data.GetOk("credentials"); ok {
+		config.Credentials = credentials.(string)
+	}
+
+	if impersonateServiceAccount, ok := data.GetOk("impersonate_service_account"); ok {
+		config.ImpersonateServiceAccount = impersonateServiceAccount.(string)
+	}
+
+	if impersonateDelegates, ok := data.GetOk("impersonate_delegates"); ok {
+		config.ImpersonateDelegates = impersonateDelegates.([]string)
+	}
+
+	if allowed_orgs, ok := data.GetOk("allowed_orgs"); ok {
+		config.AllowedOrgs = allowed_orgs.([]string)
+	}
+
+	if allowed_developer_emails, ok := data.GetOk("allowed_developer_emails"); ok {
+		config.AllowedDeveloperEmails = allowed_developer_emails.([]string)
+	}
+
+	if allowed_app_names, ok := data.GetOk("allowed_app_names"); ok {
+		config.AllowedAppNames = allowed_app_names.([]string)
+	}
+
+	if config.RetryMaxAttempts == 0 {
+		config.setRetryDefaults()
+	}
+
+	if retry_max_attempts, ok := data.GetOk("retry_max_attempts"); ok {
+		config.RetryMaxAttempts = retry_max_attempts.(int)
+	}
+
+	if retry_base_delay, ok := data.GetOk("retry_base_delay"); ok {
+		config.RetryBaseDelay = time.Duration(retry_base_delay.(int)) * time.Second
+	}
+
+	if retry_max_delay, ok := data.GetOk("retry_max_delay"); ok {
+		config.RetryMaxDelay = time.Duration(retry_max_delay.(int)) * time.Second
+	}
+
+	if retry_jitter, ok := data.GetOk("retry_jitter"); ok {
+		config.RetryJitter = retry_jitter.(float64)
+	}
+
+	if request_timeout, ok := data.GetOk("request_timeout"); ok {
+		config.RequestTimeout = time.Duration(request_timeout.(int)) * time.Second
+
+		if config.RequestTimeout <= 0 {
+			return logical.ErrorResponse("request_timeout must be greater than 0"), nil
+		}
+	}
+
+	if ca_cert, ok := data.GetOk("ca_cert"); ok {
+		config.CACert = ca_cert.(string)
+	}
+
+	if client_cert, ok := data.GetOk("client_cert"); ok {
+		config.ClientCert = client_cert.(string)
+	}
+
+	if client_key, ok := data.GetOk("client_key"); ok {
+		config.ClientKey = client_key.(string)
+	}
+
+	if tls_server_name, ok := data.GetOk("tls_server_name"); ok {
+		config.TLSServerName = tls_server_name.(string)
+	}
+
+	if insecure_skip_verify, ok := data.GetOk("insecure_skip_verify"); ok {
+		config.InsecureSkipVerify = insecure_skip_verify.(bool)
+	}
+
+	if proxy_url, ok := data.GetOk("proxy_url"); ok {
+		config.ProxyURL = proxy_url.(string)
+	}
+
+	transport, err := newTransport(config)
 
 	if err != nil {
-		return nil, err
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if lookUpTokenExpiry {
+		expiry, err := googleTokenExpiry(ctx, &http.Client{Transport: transport}, config.OAuthToken)
+
+		if err != nil {
+			b.Logger().Warn("unable to look up oauth_token expiry", "error", err)
+		}
+
+		config.OAuthTokenExpiry = expiry
+	}
+
+	if config.Credentials != "" && config.OAuthToken != "" {
+		return logical.ErrorResponse("only one of oauth_token or credentials can be set"), nil
+	}
+
+	if config.RetryMaxAttempts < 1 {
+		return logical.ErrorResponse("retry_max_attempts must be at least 1"), nil
+	}
+
+	if config.RetryBaseDelay > config.RetryMaxDelay {
+		return logical.ErrorResponse("retry_base_delay cannot be greater than retry_max_delay"), nil
+	}
+
+	if config.RetryJitter < 0 || config.RetryJitter > 1 {
+		return logical.ErrorResponse("retry_jitter must be between 0 and 1"), nil
+	}
+
+	var warnings []string
+
+	if data.Get("verify_connection").(bool) {
+		verifyWarnings, err := b.verifyConnection(ctx, req.Storage, connection, config)
+
+		b.connectionStatus(connection).record(err)
+
+		if err != nil {
+			return logical.ErrorResponse("error verifying connection: %s", err), nil
+		}
+
+		warnings = verifyWarnings
 	}
 
-	if err := req.Storage.Put(ctx, entry); err != nil {
+	if err := setConfig(ctx, req.Storage, connection, config); err != nil {
 		return nil, err
 	}
 
-	b.reset()
+	b.reset(connection)
+
+	if config.InsecureSkipVerify {
+		warnings = append(warnings, "insecure_skip_verify disables verification of the Apigee Management API server certificate; set ca_cert instead")
+	}
+
+	if len(warnings) > 0 {
+		return &logical.Response{Warnings: warnings}, nil
+	}
 
 	return nil, nil
 }
 
 func (b *apigeeBackend) pathConfigDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, configStoragePath)
+	connection := data.Get("connection").(string)
+
+	err := req.Storage.Delete(ctx, configPath(connection))
 
 	if err == nil {
-		b.reset()
+		b.reset(connection)
+		b.clearConnectionStatus(connection)
 	}
 
 	return nil, err
 }
 
-func getConfig(ctx context.Context, s logical.Storage) (*apigeeConfig, error) {
-	entry, err := s.Get(ctx, configStoragePath)
+func (b *apigeeBackend) pathConfigList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, configStoragePath+"/")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func configPath(connection string) string {
+	if connection == "" {
+		return configStoragePath
+	}
+
+	return configStoragePath + "/" + connection
+}
+
+func setConfig(ctx context.Context, s logical.Storage, connection string, config *apigeeConfig) error {
+	entry, err := logical.StorageEntryJSON(configPath(connection), config)
+
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func getConfig(ctx context.Context, s logical.Storage, connection string) (*apigeeConfig, error) {
+	entry, err := s.Get(ctx, configPath(connection))
 
 	if err != nil {
 		return nil, err
@@ -184,6 +630,123 @@ func getConfig(ctx context.Context, s logical.Storage) (*apigeeConfig, error) {
 	return config, nil
 }
 
+// checkAllowed returns an error if a non-empty orgName, developerEmail, or
+// appName is not matched by the corresponding allowlist.
+func (c *apigeeConfig) checkAllowed(orgName string, developerEmail string, appName string) error {
+	checks := []struct {
+		field   string
+		value   string
+		allowed []string
+	}{
+		{"org_name", orgName, c.AllowedOrgs},
+		{"developer_email", developerEmail, c.AllowedDeveloperEmails},
+		{"app_name", appName, c.AllowedAppNames},
+	}
+
+	for _, check := range checks {
+		if check.value == "" || len(check.allowed) == 0 {
+			continue
+		}
+
+		if !strutil.StrListContainsGlob(check.allowed, check.value) {
+			return fmt.Errorf("%s %q is not allowed by config", check.field, check.value)
+		}
+	}
+
+	return nil
+}
+
+func (b *apigeeBackend) checkAllowed(ctx context.Context, s logical.Storage, connection string, orgName string, developerEmail string, appName string) error {
+	config, err := getConfig(ctx, s, connection)
+
+	if err != nil {
+		return err
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	return config.checkAllowed(orgName, developerEmail, appName)
+}
+
+func (c *apigeeConfig) usesGoogleCredentials() bool {
+	return c.Credentials != "" || c.ImpersonateServiceAccount != ""
+}
+
+// setRetryDefaults sets the default retry settings. Configs stored before
+// retries were configurable have a zero RetryMaxAttempts and use them too.
+func (c *apigeeConfig) setRetryDefaults() {
+	c.RetryMaxAttempts = defaultRetryMaxAttempts
+	c.RetryBaseDelay = defaultRetryBaseDelay
+	c.RetryMaxDelay = defaultRetryMaxDelay
+	c.RetryJitter = defaultRetryJitter
+}
+
+func (c *apigeeConfig) retryPolicy() retryPolicy {
+	if c.RetryMaxAttempts == 0 {
+		return retryPolicy{
+			maxAttempts: defaultRetryMaxAttempts,
+			baseDelay:   defaultRetryBaseDelay,
+			maxDelay:    defaultRetryMaxDelay,
+			jitter:      defaultRetryJitter,
+		}
+	}
+
+	return retryPolicy{
+		maxAttempts: c.RetryMaxAttempts,
+		baseDelay:   c.RetryBaseDelay,
+		maxDelay:    c.RetryMaxDelay,
+		jitter:      c.RetryJitter,
+	}
+}
+
+// requestTimeout returns RequestTimeout, or the default for configs stored
+// before it was configurable.
+func (c *apigeeConfig) requestTimeout() time.Duration {
+	if c.RequestTimeout == 0 {
+		return defaultRequestTimeout
+	}
+
+	return c.RequestTimeout
+}
+
+// authMethod returns the method newClient uses to authenticate, or an empty
+// string if no credentials are configured.
+func (c *apigeeConfig) authMethod() string {
+	switch {
+	case c.Credentials != "":
+		return authMethodCredentials
+	case c.ImpersonateServiceAccount != "":
+		return authMethodImpersonation
+	case c.OAuthToken != "":
+		return authMethodOAuthToken
+	case c.Username != "" && c.Password != "":
+		return authMethodBasic
+	default:
+		return ""
+	}
+}
+
+func optionalTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t
+}
+
 const pathConfigHelpSynopsis = `Configure the Apigee backend.`
 
-const pathConfigHelpDescription = `The Apigee backend requires host and oauth_token for the Apigee Management API.`
+const pathConfigHelpDescription = `The Apigee backend requires host and one of oauth_token, credentials,
+or username and password for the Apigee Management API. With credentials, or
+with impersonate_service_account and application default credentials, the
+backend mints and refreshes its own Google access tokens.
+
+Writing config/<connection> configures an additional named connection, which
+roles select with their connection field.`
+
+const pathConfigListHelpSynopsis = `Lists the named Apigee connections.`
+
+const pathConfigListHelpDescription = `This path lists the connections configured at config/<connection>. The
+default connection at config is not listed.`
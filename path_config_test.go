@@ -2,10 +2,20 @@ package secretsengine
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
+	apigee "github.com/bstraehle/apigee-client-go"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/assert"
 )
@@ -19,14 +29,46 @@ func TestConfig(t *testing.T) {
 			"oauth_token": os.Getenv(envVarApigeeOAuthToken),
 			"username":    os.Getenv(envVarApigeeUsername),
 			"password":    os.Getenv(envVarApigeePassword),
+
+			"verify_connection": false,
 		})
 
 		assert.NoError(t, err)
 	})
 
 	t.Run("ReadConfig", func(t *testing.T) {
+		authMethod := ""
+
+		if os.Getenv(envVarApigeeOAuthToken) != "" {
+			authMethod = authMethodOAuthToken
+		} else if os.Getenv(envVarApigeeUsername) != "" && os.Getenv(envVarApigeePassword) != "" {
+			authMethod = authMethodBasic
+		}
+
 		err := testConfigRead(t, b, reqStorage, map[string]interface{}{
-			"host": os.Getenv(envVarApigeeHost),
+			"host":                     os.Getenv(envVarApigeeHost),
+			"auth_method":              authMethod,
+			"oauth_token_set":          os.Getenv(envVarApigeeOAuthToken) != "",
+			"password_set":             os.Getenv(envVarApigeePassword) != "",
+			"credentials_set":          false,
+			"token_expiry":             nil,
+			"last_api_success":         nil,
+			"last_error":               "",
+			"last_error_time":          nil,
+			"allowed_orgs":             []string(nil),
+			"allowed_developer_emails": []string(nil),
+			"allowed_app_names":        []string(nil),
+			"retry_max_attempts":       defaultRetryMaxAttempts,
+			"retry_base_delay":         defaultRetryBaseDelay.Seconds(),
+			"retry_max_delay":          defaultRetryMaxDelay.Seconds(),
+			"retry_jitter":             defaultRetryJitter,
+			"request_timeout":          defaultRequestTimeout.Seconds(),
+			"ca_cert":                  "",
+			"client_cert":              "",
+			"client_key_set":           false,
+			"tls_server_name":          "",
+			"insecure_skip_verify":     false,
+			"proxy_url":                "",
 		})
 
 		assert.NoError(t, err)
@@ -37,6 +79,419 @@ func TestConfig(t *testing.T) {
 
 		assert.NoError(t, err)
 	})
+
+	t.Run("ReadDeletedConfig", func(t *testing.T) {
+		err := testConfigRead(t, b, reqStorage, nil)
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestConfigStatus(t *testing.T) {
+	b, reqStorage, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+		"host":               "https://apigee.example.com",
+		"oauth_token":        "secret-token",
+		"oauth_token_expiry": expiry.Format(time.RFC3339),
+	})
+
+	assert.NoError(t, err)
+
+	readConfig := func(t *testing.T) map[string]interface{} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      configStoragePath,
+			Storage:   reqStorage,
+		})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, resp)
+
+		return resp.Data
+	}
+
+	t.Run("NoSecrets", func(t *testing.T) {
+		data := readConfig(t)
+
+		assert.Equal(t, authMethodOAuthToken, data["auth_method"])
+		assert.Equal(t, true, data["oauth_token_set"])
+		assert.Equal(t, false, data["password_set"])
+		assert.Equal(t, expiry, data["token_expiry"])
+		assert.NotContains(t, fmt.Sprint(data), "secret-token")
+	})
+
+	t.Run("VerifiedOnWrite", func(t *testing.T) {
+		data := readConfig(t)
+
+		assert.NotNil(t, data["last_api_success"])
+	})
+
+	t.Run("LastSuccess", func(t *testing.T) {
+		_, err := testRoleCreate(t, b, reqStorage, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "dev@example.com",
+			"app_name":        "app",
+			"api_products":    "product",
+		})
+
+		assert.NoError(t, err)
+
+		data := readConfig(t)
+
+		assert.NotNil(t, data["last_api_success"])
+		assert.Equal(t, "", data["last_error"])
+	})
+
+	t.Run("LastError", func(t *testing.T) {
+		client, err := b.getClient(context.Background(), reqStorage, "")
+
+		assert.NoError(t, err)
+
+		_, err = client.ListApiProducts(context.Background(), "missing")
+
+		assert.Error(t, err)
+
+		data := readConfig(t)
+
+		assert.Equal(t, err.Error(), data["last_error"])
+		assert.NotNil(t, data["last_error_time"])
+	})
+}
+
+func TestConfigVerifyConnection(t *testing.T) {
+	b, reqStorage, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("team-a", "product")
+	fake.addOrg("team-b", "product")
+
+	t.Run("Verified", func(t *testing.T) {
+		err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":         "https://apigee.example.com",
+			"oauth_token":  "token",
+			"allowed_orgs": "team-*",
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("NoAllowedOrgVisible", func(t *testing.T) {
+		err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":         "https://apigee.example.com",
+			"oauth_token":  "token",
+			"allowed_orgs": "other-*",
+		})
+
+		assert.ErrorContains(t, err, "no organization matching allowed_orgs")
+	})
+
+	t.Run("ListOrganizationsDenied", func(t *testing.T) {
+		fake.deny("ListOrganizations")
+		defer delete(fake.denied, "ListOrganizations")
+
+		err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":        "https://apigee.example.com",
+			"oauth_token": "token",
+		})
+
+		assert.ErrorContains(t, err, "error listing organizations at https://apigee.example.com")
+	})
+
+	t.Run("CreateKeyDenied", func(t *testing.T) {
+		fake.deny("CreateCredentials")
+		defer delete(fake.denied, "CreateCredentials")
+
+		err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":        "https://apigee.example.com",
+			"oauth_token": "token",
+		})
+
+		assert.ErrorContains(t, err, "permission to create developer app keys")
+	})
+
+	t.Run("DeleteKeyDenied", func(t *testing.T) {
+		fake.deny("DeleteCredentials")
+		defer delete(fake.denied, "DeleteCredentials")
+
+		err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":        "https://apigee.example.com",
+			"oauth_token": "token",
+		})
+
+		assert.ErrorContains(t, err, "permission to delete developer app keys")
+	})
+
+	t.Run("SkipVerification", func(t *testing.T) {
+		fake.deny("ListOrganizations")
+		defer delete(fake.denied, "ListOrganizations")
+
+		err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":              "https://apigee.example.com",
+			"oauth_token":       "token",
+			"verify_connection": false,
+		})
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestConfigVerifyConnectionRoleOrgs(t *testing.T) {
+	b, reqStorage, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("team-a", "product")
+	fake.addOrg("team-b", "product")
+	fake.deny("CreateCredentials")
+
+	t.Run("NoRoles", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      configStoragePath,
+			Data:      map[string]interface{}{"host": "https://apigee.example.com", "oauth_token": "token"},
+			Storage:   reqStorage,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{verifySkippedWarning}, resp.Warnings)
+	})
+
+	t.Run("RoleOrg", func(t *testing.T) {
+		_, err := testRoleCreate(t, b, reqStorage, map[string]interface{}{
+			"org_name":        "team-a",
+			"developer_email": "dev@example.com",
+			"app_name":        "app",
+			"api_products":    "product",
+		})
+
+		assert.NoError(t, err)
+
+		err = testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":        "https://apigee.example.com",
+			"oauth_token": "token",
+		})
+
+		assert.ErrorContains(t, err, `permission to create developer app keys in org "team-a"`)
+	})
+}
+
+func TestConfigRetry(t *testing.T) {
+	b, reqStorage, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+
+	err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+		"host":        "https://apigee.example.com",
+		"oauth_token": "token",
+	})
+
+	assert.NoError(t, err)
+
+	t.Run("Defaults", func(t *testing.T) {
+		config, err := getConfig(context.Background(), reqStorage, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, retryPolicy{
+			maxAttempts: defaultRetryMaxAttempts,
+			baseDelay:   defaultRetryBaseDelay,
+			maxDelay:    defaultRetryMaxDelay,
+			jitter:      defaultRetryJitter,
+		}, config.retryPolicy())
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      configStoragePath,
+			Data: map[string]interface{}{
+				"retry_max_attempts": 5,
+				"retry_base_delay":   "2s",
+				"retry_max_delay":    "1m",
+				"retry_jitter":       0,
+			},
+			Storage: reqStorage,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{verifySkippedWarning}, resp.Warnings)
+
+		config, err := getConfig(context.Background(), reqStorage, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, retryPolicy{maxAttempts: 5, baseDelay: 2 * time.Second, maxDelay: time.Minute}, config.retryPolicy())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, data := range []map[string]interface{}{
+			{"retry_max_attempts": 0},
+			{"retry_base_delay": "2m"},
+			{"retry_jitter": 1.5},
+		} {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      configStoragePath,
+				Data:      data,
+				Storage:   reqStorage,
+			})
+
+			assert.NoError(t, err)
+			assert.True(t, resp.IsError(), "%v", data)
+		}
+	})
+}
+
+func TestConfigGoogleCredentials(t *testing.T) {
+	b, reqStorage := getTestBackend(t)
+
+	t.Run("RejectOAuthTokenAndCredentials", func(t *testing.T) {
+		err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":        "https://apigee.googleapis.com",
+			"oauth_token": "token",
+			"credentials": `{"type": "service_account"}`,
+		})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("RejectUnsupportedCredentialsType", func(t *testing.T) {
+		_, err := googleTokenSource(context.Background(), &apigeeConfig{
+			Credentials: `{"type": "authorized_user"}`,
+		}, &http.Client{})
+
+		assert.ErrorContains(t, err, "unsupported credentials type")
+	})
+
+	t.Run("MintThroughTransport", func(t *testing.T) {
+		expiry := time.Now().Add(time.Hour)
+
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token": "minted", "token_type": "Bearer", "expires_in": 3600}`)
+		}))
+		defer srv.Close()
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		assert.NoError(t, err)
+
+		credentials, err := json.Marshal(map[string]string{
+			"type":         "service_account",
+			"client_email": "vault@example.iam.gserviceaccount.com",
+			"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+			"token_uri":    srv.URL + "/token",
+		})
+		assert.NoError(t, err)
+
+		err = testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":              "https://apigee.googleapis.com",
+			"credentials":       string(credentials),
+			"ca_cert":           string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})),
+			"verify_connection": false,
+		})
+
+		assert.NoError(t, err)
+
+		client, err := b.getClient(context.Background(), reqStorage, "")
+
+		assert.NoError(t, err)
+		assert.WithinDuration(t, expiry, client.expiresAt, time.Minute)
+	})
+
+	t.Run("ClientExpiring", func(t *testing.T) {
+		assert.False(t, (&apigeeClient{}).expiring())
+		assert.False(t, (&apigeeClient{expiresAt: time.Now().Add(time.Hour)}).expiring())
+		assert.True(t, (&apigeeClient{expiresAt: time.Now().Add(time.Minute)}).expiring())
+	})
+}
+
+func TestConfigOAuthTokenExpiry(t *testing.T) {
+	b, reqStorage := getTestBackend(t)
+
+	t.Run("ExpiredToken", func(t *testing.T) {
+		err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":               "https://apigee.example.com",
+			"oauth_token":        "token",
+			"oauth_token_expiry": time.Now().Add(-time.Minute).Format(time.RFC3339),
+			"verify_connection":  false,
+		})
+
+		assert.NoError(t, err)
+
+		_, err = b.getClient(context.Background(), reqStorage, "")
+
+		assert.ErrorIs(t, err, errManagementCredentialExpired)
+	})
+
+	t.Run("IntrospectedToken", func(t *testing.T) {
+		expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"exp": "%d"}`, expiry.Unix())
+		}))
+		defer srv.Close()
+
+		tokenInfoURL := googleTokenInfoURL
+		googleTokenInfoURL = srv.URL
+		defer func() { googleTokenInfoURL = tokenInfoURL }()
+
+		err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+			"host":              apigee.Host,
+			"oauth_token":       "token",
+			"ca_cert":           string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})),
+			"verify_connection": false,
+		})
+
+		assert.NoError(t, err)
+
+		client, err := b.getClient(context.Background(), reqStorage, "")
+
+		assert.NoError(t, err)
+		assert.True(t, expiry.Equal(client.expiresAt))
+	})
+}
+
+func TestConfigExpiringClientCached(t *testing.T) {
+	b, reqStorage := getTestBackend(t)
+
+	var clientsCreated int
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	b.clientFunc = func(ctx context.Context, config *apigeeConfig) (*apigeeClient, error) {
+		clientsCreated++
+		return &apigeeClient{managementAPI: newFakeApigee(), expiresAt: expiresAt}, nil
+	}
+
+	err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+		"host":              "https://apigee.example.com",
+		"oauth_token":       "token",
+		"verify_connection": false,
+	})
+
+	assert.NoError(t, err)
+
+	client, err := b.getClient(context.Background(), reqStorage, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, clientsCreated)
+
+	// The cached client enters the refresh window and is rebuilt once.
+	expiresAt = time.Now().Add(time.Minute)
+	client.expiresAt = expiresAt
+
+	for i := 0; i < 3; i++ {
+		client, err = b.getClient(context.Background(), reqStorage, "")
+
+		assert.NoError(t, err)
+		assert.True(t, client.expiring())
+	}
+
+	assert.Equal(t, 2, clientsCreated)
 }
 
 func testConfigCreate(t *testing.T, b logical.Backend, s logical.Storage, d map[string]interface{}) error {
@@ -86,7 +541,7 @@ func testConfigRead(t *testing.T, b logical.Backend, s logical.Storage, expected
 
 		if !ok {
 			return fmt.Errorf(`expected data["%s"] = %v but was not included in read output"`, k, expectedV)
-		} else if expectedV != actualV {
+		} else if !reflect.DeepEqual(expectedV, actualV) {
 			return fmt.Errorf(`expected data["%s"] = %v, instead got %v"`, k, expectedV, actualV)
 		}
 	}
@@ -111,3 +566,219 @@ func testConfigDelete(t *testing.T, b logical.Backend, s logical.Storage) error
 
 	return nil
 }
+
+func TestConfigConnections(t *testing.T) {
+	b, reqStorage := getTestBackend(t)
+
+	fakes := map[string]*fakeApigee{
+		"https://us.example.com": newFakeApigee(),
+		"https://eu.example.com": newFakeApigee(),
+	}
+
+	var clientsCreated int
+
+	b.clientFunc = func(ctx context.Context, config *apigeeConfig) (*apigeeClient, error) {
+		clientsCreated++
+		return &apigeeClient{managementAPI: fakes[config.Host]}, nil
+	}
+
+	for _, fake := range fakes {
+		fake.addOrg("org", "product")
+		fake.addApp("org", "dev@example.com", "app")
+	}
+
+	for name, host := range map[string]string{"us": "https://us.example.com", "eu": "https://eu.example.com"} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "config/" + name,
+			Data:      map[string]interface{}{"host": host, "oauth_token": "token"},
+			Storage:   reqStorage,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{verifySkippedWarning}, resp.Warnings)
+	}
+
+	t.Run("ListConnections", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "config/",
+			Storage:   reqStorage,
+		})
+
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"eu", "us"}, resp.Data["keys"])
+	})
+
+	t.Run("ReadConnection", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "config/eu",
+			Storage:   reqStorage,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://eu.example.com", resp.Data["host"])
+	})
+
+	t.Run("RoleUnknownConnection", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "roles/test",
+			Data: map[string]interface{}{
+				"connection":      "apac",
+				"org_name":        "org",
+				"developer_email": "dev@example.com",
+				"app_name":        "app",
+				"api_products":    "product",
+			},
+			Storage: reqStorage,
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+	})
+
+	t.Run("CredsUseRoleConnection", func(t *testing.T) {
+		_, err := testRoleCreate(t, b, reqStorage, map[string]interface{}{
+			"connection":      "eu",
+			"org_name":        "org",
+			"developer_email": "dev@example.com",
+			"app_name":        "app",
+			"api_products":    "product",
+		})
+
+		assert.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   reqStorage,
+		})
+
+		assert.NoError(t, err)
+
+		key := resp.Data["key"].(string)
+
+		assert.True(t, fakes["https://eu.example.com"].hasKey("org", "dev@example.com", "app", key))
+		assert.False(t, fakes["https://us.example.com"].hasKey("org", "dev@example.com", "app", key))
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   reqStorage,
+			Secret:    resp.Secret,
+		})
+
+		assert.NoError(t, err)
+		assert.False(t, fakes["https://eu.example.com"].hasKey("org", "dev@example.com", "app", key))
+	})
+
+	t.Run("InvalidateConnection", func(t *testing.T) {
+		_, err := b.getClient(context.Background(), reqStorage, "us")
+
+		assert.NoError(t, err)
+
+		created := clientsCreated
+
+		b.invalidate(context.Background(), "config/eu")
+
+		_, err = b.getClient(context.Background(), reqStorage, "us")
+
+		assert.NoError(t, err)
+		assert.Equal(t, created, clientsCreated)
+
+		_, err = b.getClient(context.Background(), reqStorage, "eu")
+
+		assert.NoError(t, err)
+		assert.Equal(t, created+1, clientsCreated)
+	})
+}
+
+func TestConfigAllowlists(t *testing.T) {
+	b, reqStorage, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("team-a", "product")
+	fake.addOrg("other", "product")
+	fake.addApp("team-a", "svc@team.example.com", "team-app")
+	fake.addApp("team-a", "svc@team.example.com", "other-app")
+
+	err := testConfigCreate(t, b, reqStorage, map[string]interface{}{
+		"host":                     "https://apigee.example.com",
+		"oauth_token":              "token",
+		"allowed_orgs":             "team-*",
+		"allowed_developer_emails": "*@team.example.com",
+		"allowed_app_names":        "team-*",
+	})
+
+	assert.NoError(t, err)
+
+	writeRole := func(data map[string]interface{}) *logical.Response {
+		data["api_products"] = "product"
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/test",
+			Data:      data,
+			Storage:   reqStorage,
+		})
+
+		assert.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("RejectOrg", func(t *testing.T) {
+		resp := writeRole(map[string]interface{}{"org_name": "other", "developer_email": "svc@team.example.com", "app_name": "team-app"})
+
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Error().Error(), "org_name")
+	})
+
+	t.Run("RejectDeveloperEmail", func(t *testing.T) {
+		resp := writeRole(map[string]interface{}{"org_name": "team-a", "developer_email": "svc@other.example.com", "app_name": "team-app"})
+
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Error().Error(), "developer_email")
+	})
+
+	t.Run("RejectAppName", func(t *testing.T) {
+		resp := writeRole(map[string]interface{}{"org_name": "team-a", "developer_email": "svc@team.example.com", "app_name": "other-app"})
+
+		assert.True(t, resp.IsError())
+		assert.Contains(t, resp.Error().Error(), "app_name")
+	})
+
+	t.Run("AllowedRole", func(t *testing.T) {
+		resp := writeRole(map[string]interface{}{"org_name": "team-a", "developer_email": "svc@team.example.com", "app_name": "team-app"})
+
+		assert.Nil(t, resp)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   reqStorage,
+		})
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["key"])
+	})
+
+	t.Run("RejectRenderedNamesAtCreation", func(t *testing.T) {
+		resp := writeRole(map[string]interface{}{
+			"org_name":        "team-a",
+			"developer_email": "vault-{{ .RoleName }}@other.example.com",
+			"mode":            roleModeDeveloper,
+		})
+
+		assert.Nil(t, resp)
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   reqStorage,
+		})
+
+		assert.ErrorContains(t, err, "developer_email")
+		assert.False(t, fake.hasDeveloper("team-a", "vault-test@other.example.com"))
+	})
+}
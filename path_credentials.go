@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -18,6 +20,14 @@ func pathCredentials(b *apigeeBackend) *framework.Path {
 				Description: "Name of the role",
 				Required:    true,
 			},
+			"api_products": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Comma-separated subset of the role's api_products to approve the credentials for. Defaults to all of the role's api_products",
+			},
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Lease for the credentials, capped by the role's max_ttl. Defaults to the role's ttl",
+			},
 		},
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation:   b.pathCredentialsRead,
@@ -41,13 +51,55 @@ func (b *apigeeBackend) pathCredentialsRead(ctx context.Context, req *logical.Re
 		return nil, errors.New("error retrieving role: role is nil")
 	}
 
-	return b.createCreds(ctx, req, roleEntry)
+	apiProducts := []string(roleEntry.ApiProducts)
+
+	if api_products, ok := d.GetOk("api_products"); ok {
+		apiProducts = parseApiProducts(api_products.([]string))
+
+		if len(apiProducts) == 0 {
+			return logical.ErrorResponse("api_products cannot be empty"), nil
+		}
+
+		if notAllowed := roleEntry.ApiProducts.missing(apiProducts); len(notAllowed) > 0 {
+			return logical.ErrorResponse("api_products not allowed by role %s: %s", roleName, strings.Join(notAllowed, ", ")), nil
+		}
+	}
+
+	var requestedTTL time.Duration
+
+	if ttl, ok := d.GetOk("ttl"); ok {
+		requestedTTL = time.Duration(ttl.(int)) * time.Second
+
+		if requestedTTL < 0 {
+			return logical.ErrorResponse("ttl cannot be negative"), nil
+		}
+	}
+
+	return b.createCreds(ctx, req, roleName, roleEntry, apiProducts, requestedTTL)
 }
 
-func (b *apigeeBackend) createCreds(ctx context.Context, req *logical.Request, role *apigeeRole) (*logical.Response, error) {
-	token, err := b.createCredentials(ctx, req.Storage, role)
+func (b *apigeeBackend) createCreds(ctx context.Context, req *logical.Request, roleName string, role *apigeeRole, apiProducts []string, requestedTTL time.Duration) (*logical.Response, error) {
+	ttl, maxTTL := role.leaseTTLs(b.System())
+
+	var warnings []string
+
+	if requestedTTL > 0 {
+		ttl = requestedTTL
+
+		if ttl > maxTTL {
+			warnings = append(warnings, fmt.Sprintf("ttl of %s is greater than the role's max_ttl, capping to %s", requestedTTL, maxTTL))
+			ttl = maxTTL
+		}
+	}
+
+	// Apigee cannot extend the expiry of a key, so keys expire at the end of
+	// the longest possible lease and are deleted when the lease ends.
+	keyExpiry := time.Now().Add(maxTTL)
+
+	token, walID, err := b.createCredentials(ctx, req.Storage, role, b.newTemplateData(req, roleName), apiProducts, maxTTL)
 
 	if err != nil {
+		incrCredsMetric(metricCredsCreateFailed, roleName, role.OrgName, role.mode())
 		return nil, err
 	}
 
@@ -56,46 +108,283 @@ func (b *apigeeBackend) createCreds(ctx context.Context, req *logical.Request, r
 		"developer_email": token.DeveloperEmail,
 		"app_name":        token.AppName,
 		"api_products":    token.ApiProducts,
+		"scopes":          token.Scopes,
 		"key":             token.Key,
 		"secret":          token.Secret,
 		"credentials":     token.Credentials,
 	}, map[string]interface{}{
-		"org_name":        token.OrgName,
-		"developer_email": token.DeveloperEmail,
-		"app_name":        token.AppName,
-		"api_products":    token.ApiProducts,
-		"key":             token.Key,
-		"secret":          token.Secret,
-		"credentials":     token.Credentials,
+		"org_name":         token.OrgName,
+		"developer_email":  token.DeveloperEmail,
+		"app_name":         token.AppName,
+		"api_products":     token.ApiProducts,
+		"scopes":           token.Scopes,
+		"key":              token.Key,
+		"secret":           token.Secret,
+		"credentials":      token.Credentials,
+		"role":             roleName,
+		"connection":       role.Connection,
+		"mode":             role.mode(),
+		"revoke_mode":      role.revokeMode(),
+		"revoke_retention": role.revokeRetention().String(),
+		"key_expiry":       keyExpiry.UTC().Format(time.RFC3339),
 	})
 
-	if role.TTL > 0 {
-		resp.Secret.TTL = role.TTL
+	resp.Secret.TTL = ttl
+	resp.Secret.MaxTTL = maxTTL
+
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+
+	// The key is rolled back if the WAL entry remains, so the credentials
+	// must not be returned when the entry cannot be removed.
+	if err := framework.DeleteWAL(ctx, req.Storage, walID); err != nil {
+		incrCredsMetric(metricCredsCreateFailed, roleName, role.OrgName, role.mode())
+		return nil, fmt.Errorf("error committing WAL entry: %w", err)
 	}
 
+	incrCredsMetric(metricCredsCreated, roleName, token.OrgName, role.mode())
+
 	return resp, nil
 }
 
-func (b *apigeeBackend) createCredentials(ctx context.Context, s logical.Storage, role *apigeeRole) (*apigeeToken, error) {
-	client, err := b.getClient(ctx, s)
+func (b *apigeeBackend) createCredentials(ctx context.Context, s logical.Storage, role *apigeeRole, data templateData, apiProducts []string, ttl time.Duration) (*apigeeToken, string, error) {
+	client, err := b.getClient(ctx, s, role.Connection)
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	attributes, err := renderTemplates(role.Attributes, data)
+
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating attribute %w", err)
+	}
+
+	var token *apigeeToken
+	var walID string
+
+	switch role.mode() {
+	case roleModeApp:
+		token, walID, err = b.createAppCredentials(ctx, s, client, role, data, apiProducts, attributes, ttl)
+	case roleModeDeveloper:
+		token, walID, err = b.createDeveloperCredentials(ctx, s, client, role, data, apiProducts, attributes, ttl)
+	default:
+		token, walID, err = b.createKeyCredentials(ctx, s, client, role, apiProducts, attributes, ttl)
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := approveCredentials(ctx, client, role, token); err != nil {
+		// The WAL entry is kept to roll back the credentials if they cannot
+		// be deleted now.
+		if deleteErr := deleteLeaseCredentials(ctx, client, role.mode(), token); deleteErr != nil {
+			b.Logger().Error("error deleting credentials after failed approval", "key", token.Key, "error", deleteErr)
+		} else {
+			b.deleteWAL(ctx, s, walID)
+		}
+
+		return nil, "", err
+	}
+
+	return token, walID, nil
+}
+
+func (b *apigeeBackend) newTemplateData(req *logical.Request, roleName string) templateData {
+	data := templateData{
+		RoleName:    roleName,
+		EntityID:    req.EntityID,
+		DisplayName: req.DisplayName,
+		RequestID:   req.ID,
+		Time:        time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if req.EntityID == "" {
+		return data
+	}
+
+	entity, err := b.System().EntityInfo(req.EntityID)
+
+	if err != nil {
+		b.Logger().Warn("error looking up entity for templates", "entity_id", req.EntityID, "error", err)
+	} else if entity != nil {
+		data.EntityName = entity.Name
+	}
+
+	return data
+}
+
+func (b *apigeeBackend) createKeyCredentials(ctx context.Context, s logical.Storage, client *apigeeClient, role *apigeeRole, apiProducts []string, attributes map[string]string, ttl time.Duration) (*apigeeToken, string, error) {
+	if err := b.checkAllowed(ctx, s, role.Connection, role.OrgName, role.DeveloperEmail, role.AppName); err != nil {
+		return nil, "", err
+	}
+
+	entry := &walCredentials{
+		Connection:     role.Connection,
+		Mode:           roleModeKey,
+		OrgName:        role.OrgName,
+		DeveloperEmail: role.DeveloperEmail,
+		AppName:        role.AppName,
+	}
+
+	intentWALID, err := framework.PutWAL(ctx, s, walTypeCredentials, entry)
+
+	if err != nil {
+		return nil, "", fmt.Errorf("error writing WAL entry: %w", err)
 	}
 
 	var token *apigeeToken
 
-	token, err = createCredentials(ctx, client, role.OrgName, role.DeveloperEmail, role.AppName, role.ApiProducts, int(role.TTL.Seconds()))
+	// Attributes and scopes are set once the key is recorded in the WAL, so
+	// that the key is rolled back if setting them fails.
+	token, err = createCredentials(ctx, client, role.OrgName, role.DeveloperEmail, role.AppName, apiProducts, nil, nil, int(ttl.Seconds()))
 
 	if err != nil {
-		return nil, fmt.Errorf("error creating credentials: %w", err)
+		// Unless Apigee rejected the request, a key may have been created
+		// anyway, and the WAL entry is kept to report it.
+		if isRejected(err) {
+			b.deleteWAL(ctx, s, intentWALID)
+		}
+
+		return nil, "", fmt.Errorf("error creating credentials: %w", err)
 	}
 
 	if token == nil {
-		return nil, errors.New("error creating credentials")
+		b.deleteWAL(ctx, s, intentWALID)
+		return nil, "", errors.New("error creating credentials")
+	}
+
+	entry.Key = token.Key
+
+	walID, err := framework.PutWAL(ctx, s, walTypeCredentials, entry)
+
+	if err != nil {
+		if err := deleteCredentials(ctx, client, token.OrgName, token.DeveloperEmail, token.AppName, token.Key); err != nil {
+			b.Logger().Error("error deleting credentials after failed WAL write", "key", token.Key, "error", err)
+		}
+
+		return nil, "", fmt.Errorf("error writing WAL entry: %w", err)
+	}
+
+	b.deleteWAL(ctx, s, intentWALID)
+
+	if err := updateCredentials(ctx, client, token, attributes, role.Scopes); err != nil {
+		// The WAL entry is kept to roll back the key if it cannot be deleted
+		// now.
+		if deleteErr := deleteCredentials(ctx, client, token.OrgName, token.DeveloperEmail, token.AppName, token.Key); deleteErr != nil {
+			b.Logger().Error("error deleting credentials after failed update", "key", token.Key, "error", deleteErr)
+		} else {
+			b.deleteWAL(ctx, s, walID)
+		}
+
+		return nil, "", err
+	}
+
+	return token, walID, nil
+}
+
+func (b *apigeeBackend) createAppCredentials(ctx context.Context, s logical.Storage, client *apigeeClient, role *apigeeRole, data templateData, apiProducts []string, attributes map[string]string, ttl time.Duration) (*apigeeToken, string, error) {
+	appName, err := renderTemplate(role.AppNameTemplate, data)
+
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating app name: %w", err)
+	}
+
+	if err := b.checkAllowed(ctx, s, role.Connection, role.OrgName, role.DeveloperEmail, appName); err != nil {
+		return nil, "", err
+	}
+
+	walID, err := framework.PutWAL(ctx, s, walTypeCredentials, &walCredentials{
+		Connection:     role.Connection,
+		Mode:           roleModeApp,
+		OrgName:        role.OrgName,
+		DeveloperEmail: role.DeveloperEmail,
+		AppName:        appName,
+	})
+
+	if err != nil {
+		return nil, "", fmt.Errorf("error writing WAL entry: %w", err)
+	}
+
+	token, err := createApp(ctx, client, role.OrgName, role.DeveloperEmail, appName, apiProducts, attributes, role.Scopes, int(ttl.Seconds()))
+
+	if err != nil {
+		// Unless Apigee rejected the request, the app may have been created
+		// anyway, so the WAL entry is kept for rollback to remove it.
+		if isRejected(err) {
+			b.deleteWAL(ctx, s, walID)
+		}
+
+		return nil, "", err
+	}
+
+	return token, walID, nil
+}
+
+func (b *apigeeBackend) createDeveloperCredentials(ctx context.Context, s logical.Storage, client *apigeeClient, role *apigeeRole, data templateData, apiProducts []string, attributes map[string]string, ttl time.Duration) (*apigeeToken, string, error) {
+	values, err := renderTemplates(map[string]string{
+		"developer_email":      role.DeveloperEmail,
+		"developer_first_name": role.DeveloperFirstName,
+		"developer_last_name":  role.DeveloperLastName,
+		"app_name":             role.AppNameTemplate,
+	}, data)
+
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating %w", err)
+	}
+
+	developerAttributes, err := renderTemplates(role.DeveloperAttributes, data)
+
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating developer attribute %w", err)
+	}
+
+	developerEmail := values["developer_email"]
+	appName := values["app_name"]
+
+	if err := b.checkAllowed(ctx, s, role.Connection, role.OrgName, developerEmail, appName); err != nil {
+		return nil, "", err
+	}
+
+	walID, err := framework.PutWAL(ctx, s, walTypeCredentials, &walCredentials{
+		Connection:     role.Connection,
+		Mode:           roleModeDeveloper,
+		OrgName:        role.OrgName,
+		DeveloperEmail: developerEmail,
+		AppName:        appName,
+	})
+
+	if err != nil {
+		return nil, "", fmt.Errorf("error writing WAL entry: %w", err)
+	}
+
+	err = client.CreateDeveloper(ctx, role.OrgName, developerEmail, values["developer_first_name"], values["developer_last_name"], developerAttributes)
+
+	if err != nil {
+		if isRejected(err) {
+			b.deleteWAL(ctx, s, walID)
+		}
+
+		return nil, "", fmt.Errorf("error creating developer: %w", err)
+	}
+
+	token, err := createApp(ctx, client, role.OrgName, developerEmail, appName, apiProducts, attributes, role.Scopes, int(ttl.Seconds()))
+
+	if err != nil {
+		// The WAL entry is kept to roll back the developer if it cannot be
+		// deleted now.
+		if deleteErr := client.DeleteDeveloper(ctx, role.OrgName, developerEmail); deleteErr != nil {
+			b.Logger().Error("error deleting developer after failed app creation", "developer_email", developerEmail, "error", deleteErr)
+		} else {
+			b.deleteWAL(ctx, s, walID)
+		}
+
+		return nil, "", err
 	}
 
-	return token, nil
+	return token, walID, nil
 }
 
 const pathCredentialsHelpSyn = `Generate Apigee credentials from Vault role.`
@@ -7,15 +7,17 @@ import (
 	"time"
 
 	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/logging"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
 )
 
 func newTestEnv() (*testEnv, error) {
 	ctx := context.Background()
 
-	defaultLease, _ := time.ParseDuration("1d")
-	maxLease, _ := time.ParseDuration("1d")
+	defaultLease, _ := time.ParseDuration("24h")
+	maxLease, _ := time.ParseDuration("48h")
 
 	conf := &logical.BackendConfig{
 		System: &logical.StaticSystemView{
@@ -31,7 +33,7 @@ func newTestEnv() (*testEnv, error) {
 		return nil, err
 	}
 
-	return &testEnv{
+	e := &testEnv{
 		Host:       os.Getenv(envVarApigeeHost),
 		OAuthToken: os.Getenv(envVarApigeeOAuthToken),
 		Username:   os.Getenv(envVarApigeeUsername),
@@ -45,7 +47,13 @@ func newTestEnv() (*testEnv, error) {
 		Backend: b,
 		Context: ctx,
 		Storage: &logical.InmemStorage{},
-	}, nil
+	}
+
+	if e.Host == "" {
+		e.useFake()
+	}
+
+	return e, nil
 }
 
 func TestCreds(t *testing.T) {
@@ -60,5 +68,450 @@ func TestCreds(t *testing.T) {
 	t.Run("ReadCred1", testEnv.ReadCred)
 	t.Run("ReadCred2", testEnv.ReadCred)
 	t.Run("ReadCred3", testEnv.ReadCred)
-	t.Run("DeleteCreds", testEnv.DeleteCreds)
+	t.Run("RenewCred", testEnv.RenewCred)
+	t.Run("RevokeCreds", testEnv.RevokeCreds)
+}
+
+func TestCredsAppMode(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addDeveloper("org", "dev@example.com")
+
+	_, err := testRoleCreate(t, b, s, map[string]interface{}{
+		"org_name":        "org",
+		"developer_email": "dev@example.com",
+		"api_products":    "product",
+		"mode":            roleModeApp,
+		"ttl":             "1h",
+	})
+
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test",
+		Storage:   s,
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	appName := resp.Data["app_name"].(string)
+
+	require.Regexp(t, `^vault-test-[a-z0-9]{8}$`, appName)
+	require.True(t, fake.hasKey("org", "dev@example.com", appName, resp.Data["key"].(string)))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    resp.Secret,
+	})
+
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	require.Empty(t, fake.apps("org", "dev@example.com"))
+}
+
+func TestCredsDeveloperMode(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+
+	_, err := testRoleCreate(t, b, s, map[string]interface{}{
+		"org_name":             "org",
+		"developer_email":      "{{ .RoleName }}-{{ random 8 | lowercase }}@example.com",
+		"api_products":         "product",
+		"mode":                 roleModeDeveloper,
+		"developer_attributes": map[string]string{"created_by": "vault-{{ .RoleName }}"},
+		"ttl":                  "1h",
+	})
+
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test",
+		Storage:   s,
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	developerEmail := resp.Data["developer_email"].(string)
+	appName := resp.Data["app_name"].(string)
+
+	require.Regexp(t, `^test-[a-z0-9]{8}@example\.com$`, developerEmail)
+
+	developer := fake.getDeveloper("org", developerEmail)
+
+	require.NotNil(t, developer)
+	require.Equal(t, "Vault", developer.firstName)
+	require.Equal(t, "test", developer.lastName)
+	require.Equal(t, map[string]string{"created_by": "vault-test"}, developer.attributes)
+	require.True(t, fake.hasKey("org", developerEmail, appName, resp.Data["key"].(string)))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    resp.Secret,
+	})
+
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	require.Empty(t, fake.developers("org"))
+}
+
+func TestCredsApiProductsSubset(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "orders", "payments", "admin")
+	fake.addApp("org", "dev@example.com", "app")
+
+	_, err := testRoleCreate(t, b, s, map[string]interface{}{
+		"org_name":        "org",
+		"developer_email": "dev@example.com",
+		"app_name":        "app",
+		"api_products":    "orders,payments",
+		"ttl":             "1h",
+	})
+
+	require.NoError(t, err)
+
+	t.Run("Subset", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "creds/test",
+			Data:      map[string]interface{}{"api_products": "orders"},
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.IsError())
+
+		require.Equal(t, []string{"orders"}, resp.Data["api_products"])
+		require.Equal(t, []string{"orders"}, resp.Secret.InternalData["api_products"])
+		require.Equal(t, []string{"orders"}, fake.getKey("org", "dev@example.com", "app", resp.Data["key"].(string)).apiProducts)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.Equal(t, []string{"orders", "payments"}, resp.Data["api_products"])
+	})
+
+	t.Run("NotAllowed", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "creds/test",
+			Data:      map[string]interface{}{"api_products": "orders,admin"},
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "admin")
+	})
+}
+
+func TestCredsRequestedTTL(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	_, err := testRoleCreate(t, b, s, map[string]interface{}{
+		"org_name":        "org",
+		"developer_email": "dev@example.com",
+		"app_name":        "app",
+		"api_products":    "product",
+		"ttl":             "1h",
+		"max_ttl":         "2h",
+	})
+
+	require.NoError(t, err)
+
+	t.Run("Shorter", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "creds/test",
+			Data:      map[string]interface{}{"ttl": "5m"},
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.Equal(t, 5*time.Minute, resp.Secret.TTL)
+		require.Empty(t, resp.Warnings)
+
+		key := fake.getKey("org", "dev@example.com", "app", resp.Data["key"].(string))
+
+		require.WithinDuration(t, time.Now().Add(2*time.Hour), key.expiresAt, time.Minute)
+	})
+
+	t.Run("CappedByMaxTTL", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "creds/test",
+			Data:      map[string]interface{}{"ttl": "3h"},
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.Equal(t, 2*time.Hour, resp.Secret.TTL)
+		require.NotEmpty(t, resp.Warnings)
+
+		key := fake.getKey("org", "dev@example.com", "app", resp.Data["key"].(string))
+
+		require.WithinDuration(t, time.Now().Add(2*time.Hour), key.expiresAt, time.Minute)
+	})
+}
+
+func TestCredsRenewCappedByKeyExpiry(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	_, err := testRoleCreate(t, b, s, map[string]interface{}{
+		"org_name":        "org",
+		"developer_email": "dev@example.com",
+		"app_name":        "app",
+		"api_products":    "product",
+		"ttl":             "1h",
+		"max_ttl":         "2h",
+	})
+
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/test",
+		Storage:   s,
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	key := fake.getKey("org", "dev@example.com", "app", resp.Data["key"].(string))
+
+	require.WithinDuration(t, time.Now().Add(2*time.Hour), key.expiresAt, time.Minute)
+
+	_, err = testRoleUpdate(t, b, s, map[string]interface{}{
+		"org_name":        "org",
+		"developer_email": "dev@example.com",
+		"app_name":        "app",
+		"api_products":    "product",
+		"ttl":             "1h",
+		"max_ttl":         "4h",
+	})
+
+	require.NoError(t, err)
+
+	secret := resp.Secret
+	secret.IssueTime = time.Now()
+	secret.Increment = 3 * time.Hour
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   s,
+		Secret:    secret,
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	require.LessOrEqual(t, resp.Secret.TTL, 2*time.Hour)
+	require.Greater(t, resp.Secret.TTL, 2*time.Hour-time.Minute)
+	require.NotEmpty(t, resp.Warnings)
+	require.Equal(t, key.expiresAt, fake.getKey("org", "dev@example.com", "app", resp.Secret.InternalData["key"].(string)).expiresAt)
+}
+
+func TestCredsAttributes(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	b.System().(*logical.StaticSystemView).EntityVal = &logical.Entity{ID: "entity-id", Name: "ci-runner"}
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	attributes := map[string]interface{}{
+		"vault_role":         "{{ .RoleName }}",
+		"vault_entity_id":    "{{ .EntityID }}",
+		"vault_entity_name":  "{{ .EntityName }}",
+		"vault_display_name": "{{ .DisplayName }}",
+		"vault_request_id":   "{{ .RequestID }}",
+	}
+
+	readCreds := func(t *testing.T) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			ID:          "request-id",
+			Operation:   logical.ReadOperation,
+			Path:        "creds/test",
+			EntityID:    "entity-id",
+			DisplayName: "approle-ci",
+			Storage:     s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.IsError())
+
+		return resp
+	}
+
+	expected := map[string]string{
+		"vault_role":         "test",
+		"vault_entity_id":    "entity-id",
+		"vault_entity_name":  "ci-runner",
+		"vault_display_name": "approle-ci",
+		"vault_request_id":   "request-id",
+	}
+
+	t.Run("KeyMode", func(t *testing.T) {
+		_, err := testRoleCreate(t, b, s, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "dev@example.com",
+			"app_name":        "app",
+			"api_products":    "product",
+			"attributes":      attributes,
+		})
+
+		require.NoError(t, err)
+
+		resp := readCreds(t)
+		key := fake.getKey("org", "dev@example.com", "app", resp.Data["key"].(string))
+
+		require.Equal(t, expected, key.attributes)
+	})
+
+	t.Run("AppMode", func(t *testing.T) {
+		_, err := testRoleUpdate(t, b, s, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "dev@example.com",
+			"api_products":    "product",
+			"mode":            roleModeApp,
+		})
+
+		require.NoError(t, err)
+
+		resp := readCreds(t)
+		developer := fake.getDeveloper("org", "dev@example.com")
+
+		require.Equal(t, expected, developer.apps[resp.Data["app_name"].(string)].attributes)
+	})
+
+	t.Run("InvalidTemplate", func(t *testing.T) {
+		resp, err := testRoleUpdate(t, b, s, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "dev@example.com",
+			"app_name":        "app",
+			"api_products":    "product",
+			"attributes":      map[string]interface{}{"vault_lease": "{{ .Unknown }}"},
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.IsError())
+	})
+}
+
+func TestCredsScopes(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	for _, mode := range []string{roleModeKey, roleModeApp} {
+		t.Run(mode, func(t *testing.T) {
+			_, err := testRoleUpdate(t, b, s, map[string]interface{}{
+				"org_name":        "org",
+				"developer_email": "dev@example.com",
+				"app_name":        "app",
+				"api_products":    "product",
+				"scopes":          "orders.read,orders.write",
+				"mode":            mode,
+			})
+
+			require.NoError(t, err)
+
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "creds/test",
+				Storage:   s,
+			})
+
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+			require.Equal(t, []string{"orders.read", "orders.write"}, resp.Data["scopes"])
+
+			key := fake.getKey("org", "dev@example.com", resp.Data["app_name"].(string), resp.Data["key"].(string))
+
+			require.Equal(t, []string{"orders.read", "orders.write"}, key.scopes)
+		})
+	}
+}
+
+func TestCredsApproval(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "orders", "payments")
+	fake.addApp("org", "dev@example.com", "app")
+
+	_, err := testRoleCreate(t, b, s, map[string]interface{}{
+		"org_name":             "org",
+		"developer_email":      "dev@example.com",
+		"app_name":             "app",
+		"api_products":         "orders,payments",
+		"approve_key":          true,
+		"approve_api_products": true,
+	})
+
+	require.NoError(t, err)
+
+	t.Run("Approved", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		key := fake.getKey("org", "dev@example.com", "app", resp.Data["key"].(string))
+
+		require.True(t, key.approved)
+		require.Equal(t, map[string]bool{"orders": true, "payments": true}, key.approvedProducts)
+	})
+
+	t.Run("ApprovalFailed", func(t *testing.T) {
+		fake.setUnapprovable("org", "payments")
+
+		before := len(fake.getDeveloper("org", "dev@example.com").apps["app"].keys)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.Error(t, err)
+		require.Nil(t, resp)
+		require.Contains(t, err.Error(), "payments")
+		require.Len(t, fake.getDeveloper("org", "dev@example.com").apps["app"].keys, before)
+
+		walIDs, err := framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Empty(t, walIDs)
+	})
 }
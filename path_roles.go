@@ -2,7 +2,9 @@ package secretsengine
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
@@ -15,14 +17,41 @@ const (
 
 	pathRoleListHelpSynopsis    = `Lists roles for generating Apigee credentials.`
 	pathRoleListHelpDescription = `This path lists Vault roles for generating Apigee credentials.`
+
+	roleModeKey       = "key"
+	roleModeApp       = "app"
+	roleModeDeveloper = "developer"
+
+	defaultAppNameTemplate = `vault-{{ .RoleName }}-{{ random 8 | lowercase }}`
+
+	defaultDeveloperFirstName = "Vault"
+	defaultDeveloperLastName  = "{{ .RoleName }}"
 )
 
 type apigeeRole struct {
-	OrgName        string        `json:"org_name"`
-	DeveloperEmail string        `json:"developer_email"`
-	AppName        string        `json:"app_name"`
-	ApiProducts    string        `json:"api_products"`
-	TTL            time.Duration `json:"ttl"`
+	Connection     string         `json:"connection"`
+	OrgName        string         `json:"org_name"`
+	DeveloperEmail string         `json:"developer_email"`
+	AppName        string         `json:"app_name"`
+	ApiProducts    apiProductList `json:"api_products"`
+	TTL            time.Duration  `json:"ttl"`
+	MaxTTL         time.Duration  `json:"max_ttl"`
+
+	Attributes map[string]string `json:"attributes"`
+	Scopes     []string          `json:"scopes"`
+
+	ApproveKey         bool `json:"approve_key"`
+	ApproveApiProducts bool `json:"approve_api_products"`
+
+	RevokeMode      string        `json:"revoke_mode"`
+	RevokeRetention time.Duration `json:"revoke_retention"`
+
+	Mode            string `json:"mode"`
+	AppNameTemplate string `json:"app_name_template"`
+
+	DeveloperFirstName  string            `json:"developer_first_name"`
+	DeveloperLastName   string            `json:"developer_last_name"`
+	DeveloperAttributes map[string]string `json:"developer_attributes"`
 }
 
 func pathRoles(b *apigeeBackend) []*framework.Path {
@@ -35,6 +64,10 @@ func pathRoles(b *apigeeBackend) []*framework.Path {
 					Description: "The role name",
 					Required:    true,
 				},
+				"connection": {
+					Type:        framework.TypeString,
+					Description: "The name of the connection at config/<connection> used to generate credentials. If not set, the connection at config is used",
+				},
 				"org_name": {
 					Type:        framework.TypeString,
 					Description: "The org_name for the Apigee Management API",
@@ -42,41 +75,100 @@ func pathRoles(b *apigeeBackend) []*framework.Path {
 				},
 				"developer_email": {
 					Type:        framework.TypeString,
-					Description: "The developer_email for the Apigee Management API",
+					Description: "The developer_email for the Apigee Management API. Used as a template for the emails of the developers created when mode is developer",
 					Required:    true,
 				},
 				"app_name": {
 					Type:        framework.TypeString,
-					Description: "The app_name for the Apigee Management API",
-					Required:    true,
+					Description: "The app_name for the Apigee Management API. Required when mode is key",
 				},
 				"api_products": {
-					Type:        framework.TypeString,
-					Description: "The api_products for the Apigee Management API",
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma-separated list of the API products the credentials are approved for",
 					Required:    true,
 				},
+				"skip_api_products_validation": {
+					Type:        framework.TypeBool,
+					Description: "Skip checking that api_products exist in org_name. Not stored with the role",
+				},
 				"ttl": {
 					Type:        framework.TypeDurationSecond,
-					Description: "Lease for credentials",
+					Description: "Default lease for credentials. If not set or set to 0, will use system default.",
+				},
+				"max_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Maximum lease for credentials. If not set or set to 0, will use system default.",
+				},
+				"attributes": {
+					Type:        framework.TypeKVPairs,
+					Description: "Custom attributes, as templates, set on each generated key, or on each generated app when mode is app or developer",
+				},
+				"scopes": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma-separated list of OAuth scopes the generated credentials are restricted to",
+				},
+				"approve_key": {
+					Type:        framework.TypeBool,
+					Description: "Approve each generated key. The credentials request fails if the key cannot be approved",
+				},
+				"approve_api_products": {
+					Type:        framework.TypeBool,
+					Description: "Approve the API product associations of each generated key, e.g. for products with manual approval. The credentials request fails if a product cannot be approved",
+				},
+				"revoke_mode": {
+					Type:          framework.TypeString,
+					Description:   "What happens to a key when its lease is revoked: delete deletes it, revoke sets its status to revoked, and expire is the same as revoke, as Apigee cannot change the expiry of a key. Revoked keys are deleted after revoke_retention",
+					Default:       revokeModeDelete,
+					AllowedValues: []interface{}{revokeModeDelete, revokeModeRevoke, revokeModeExpire},
+				},
+				"revoke_retention": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How long revoked keys are kept before they are deleted. If not set or set to 0, keys are kept for 30 days",
+					Default:     int(defaultRevokeRetention.Seconds()),
+				},
+				"mode": {
+					Type:          framework.TypeString,
+					Description:   "How credentials are issued: key adds a key to app_name per lease, app creates a developer app per lease, developer creates a developer and app per lease",
+					Default:       roleModeKey,
+					AllowedValues: []interface{}{roleModeKey, roleModeApp, roleModeDeveloper},
+				},
+				"app_name_template": {
+					Type:        framework.TypeString,
+					Description: "Template for the names of the developer apps created when mode is app or developer",
+					Default:     defaultAppNameTemplate,
+				},
+				"developer_first_name": {
+					Type:        framework.TypeString,
+					Description: "Template for the first name of the developers created when mode is developer",
+					Default:     defaultDeveloperFirstName,
+				},
+				"developer_last_name": {
+					Type:        framework.TypeString,
+					Description: "Template for the last name of the developers created when mode is developer",
+					Default:     defaultDeveloperLastName,
+				},
+				"developer_attributes": {
+					Type:        framework.TypeKVPairs,
+					Description: "Custom attributes, as templates, of the developers created when mode is developer",
 				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
-					logical.ReadOperation: &framework.PathOperation{
-						Callback: b.pathRolesRead,
-					},
-					logical.CreateOperation: &framework.PathOperation{
-						Callback: b.pathRolesWrite,
-					},
-					logical.UpdateOperation: &framework.PathOperation{
-						Callback: b.pathRolesWrite,
-					},
-					logical.DeleteOperation: &framework.PathOperation{
-						Callback: b.pathRolesDelete,
-					},
-				},
-				ExistenceCheck:  b.pathRolesExistenceCheck,
-				HelpSynopsis:    pathRoleHelpSynopsis,
-				HelpDescription: pathRoleHelpDescription,
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathRolesRead,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathRolesWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathRolesWrite,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathRolesDelete,
+				},
+			},
+			ExistenceCheck:  b.pathRolesExistenceCheck,
+			HelpSynopsis:    pathRoleHelpSynopsis,
+			HelpDescription: pathRoleHelpDescription,
 		},
 		{
 			Pattern: "roles/?$",
@@ -108,6 +200,20 @@ func (b *apigeeBackend) pathRolesWrite(ctx context.Context, req *logical.Request
 		role = &apigeeRole{}
 	}
 
+	if connection, ok := d.GetOk("connection"); ok {
+		role.Connection = connection.(string)
+	}
+
+	config, err := getConfig(ctx, req.Storage, role.Connection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil && role.Connection != "" {
+		return logical.ErrorResponse("connection %q not found", role.Connection), nil
+	}
+
 	if org_name, ok := d.GetOk("org_name"); ok {
 		role.OrgName = org_name.(string)
 	} else if !ok {
@@ -120,22 +226,144 @@ func (b *apigeeBackend) pathRolesWrite(ctx context.Context, req *logical.Request
 		return nil, fmt.Errorf("missing developer_email in role")
 	}
 
+	if mode, ok := d.GetOk("mode"); ok {
+		role.Mode = mode.(string)
+	} else if role.Mode == "" {
+		role.Mode = d.Get("mode").(string)
+	}
+
 	if app_name, ok := d.GetOk("app_name"); ok {
 		role.AppName = app_name.(string)
-	} else if !ok {
+	} else if role.Mode == roleModeKey {
 		return nil, fmt.Errorf("missing app_name in role")
 	}
 
 	if api_products, ok := d.GetOk("api_products"); ok {
-		role.ApiProducts = api_products.(string)
-	} else if !ok {
+		role.ApiProducts = parseApiProducts(api_products.([]string))
+	}
+
+	if len(role.ApiProducts) == 0 {
 		return nil, fmt.Errorf("missing api_products in role")
 	}
 
 	if ttl, ok := d.GetOk("ttl"); ok {
 		role.TTL = time.Duration(ttl.(int)) * time.Second
-	} else if !ok {
-		return nil, fmt.Errorf("missing ttl in role")
+	}
+
+	if maxTTL, ok := d.GetOk("max_ttl"); ok {
+		role.MaxTTL = time.Duration(maxTTL.(int)) * time.Second
+	}
+
+	if role.MaxTTL != 0 && role.TTL > role.MaxTTL {
+		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
+	}
+
+	if app_name_template, ok := d.GetOk("app_name_template"); ok {
+		role.AppNameTemplate = app_name_template.(string)
+	} else if role.AppNameTemplate == "" {
+		role.AppNameTemplate = d.Get("app_name_template").(string)
+	}
+
+	if developer_first_name, ok := d.GetOk("developer_first_name"); ok {
+		role.DeveloperFirstName = developer_first_name.(string)
+	} else if role.DeveloperFirstName == "" {
+		role.DeveloperFirstName = d.Get("developer_first_name").(string)
+	}
+
+	if developer_last_name, ok := d.GetOk("developer_last_name"); ok {
+		role.DeveloperLastName = developer_last_name.(string)
+	} else if role.DeveloperLastName == "" {
+		role.DeveloperLastName = d.Get("developer_last_name").(string)
+	}
+
+	if attributes, ok := d.GetOk("attributes"); ok {
+		role.Attributes = attributes.(map[string]string)
+	}
+
+	if scopes, ok := d.GetOk("scopes"); ok {
+		role.Scopes = scopes.([]string)
+	}
+
+	if approve_key, ok := d.GetOk("approve_key"); ok {
+		role.ApproveKey = approve_key.(bool)
+	}
+
+	if approve_api_products, ok := d.GetOk("approve_api_products"); ok {
+		role.ApproveApiProducts = approve_api_products.(bool)
+	}
+
+	if revoke_mode, ok := d.GetOk("revoke_mode"); ok {
+		role.RevokeMode = revoke_mode.(string)
+	}
+
+	if revoke_retention, ok := d.GetOk("revoke_retention"); ok {
+		role.RevokeRetention = time.Duration(revoke_retention.(int)) * time.Second
+	}
+
+	if role.RevokeRetention < 0 {
+		return logical.ErrorResponse("revoke_retention cannot be negative"), nil
+	}
+
+	if developer_attributes, ok := d.GetOk("developer_attributes"); ok {
+		role.DeveloperAttributes = developer_attributes.(map[string]string)
+	}
+
+	if role.Mode != roleModeKey && role.Mode != roleModeApp && role.Mode != roleModeDeveloper {
+		return logical.ErrorResponse("invalid mode %q", role.Mode), nil
+	}
+
+	if rm := role.revokeMode(); rm != revokeModeDelete && rm != revokeModeRevoke && rm != revokeModeExpire {
+		return logical.ErrorResponse("invalid revoke_mode %q", rm), nil
+	}
+
+	templates := map[string]string{
+		"app_name_template":    role.AppNameTemplate,
+		"developer_first_name": role.DeveloperFirstName,
+		"developer_last_name":  role.DeveloperLastName,
+	}
+
+	if role.Mode == roleModeDeveloper {
+		templates["developer_email"] = role.DeveloperEmail
+	}
+
+	for name, value := range role.DeveloperAttributes {
+		templates["developer_attributes."+name] = value
+	}
+
+	for name, value := range role.Attributes {
+		templates["attributes."+name] = value
+	}
+
+	for field, text := range templates {
+		if err := validateTemplate(text); err != nil {
+			return logical.ErrorResponse("invalid %s: %s", field, err), nil
+		}
+	}
+
+	if config != nil {
+		developerEmail := role.DeveloperEmail
+
+		// In developer mode developer_email is a template, so the rendered
+		// email is checked when credentials are created.
+		if role.Mode == roleModeDeveloper {
+			developerEmail = ""
+		}
+
+		appName := ""
+
+		if role.Mode == roleModeKey {
+			appName = role.AppName
+		}
+
+		if err := config.checkAllowed(role.OrgName, developerEmail, appName); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if !d.Get("skip_api_products_validation").(bool) {
+		if resp, err := b.validateApiProducts(ctx, req.Storage, role.Connection, role.OrgName, role.ApiProducts); resp != nil || err != nil {
+			return resp, err
+		}
 	}
 
 	if err := setRole(ctx, req.Storage, name.(string), role); err != nil {
@@ -235,12 +463,150 @@ func (b *apigeeBackend) getRole(ctx context.Context, s logical.Storage, name str
 
 func (r *apigeeRole) toResponseData() map[string]interface{} {
 	respData := map[string]interface{}{
-		"org_name":        r.OrgName,
-		"developer_email": r.DeveloperEmail,
-		"app_name":        r.AppName,
-		"api_products":    r.ApiProducts,
-		"ttl":             r.TTL.Seconds(),
+		"connection":           r.Connection,
+		"org_name":             r.OrgName,
+		"developer_email":      r.DeveloperEmail,
+		"app_name":             r.AppName,
+		"api_products":         []string(r.ApiProducts),
+		"ttl":                  r.TTL.Seconds(),
+		"max_ttl":              r.MaxTTL.Seconds(),
+		"attributes":           r.Attributes,
+		"scopes":               r.Scopes,
+		"approve_key":          r.ApproveKey,
+		"approve_api_products": r.ApproveApiProducts,
+		"revoke_mode":          r.revokeMode(),
+		"revoke_retention":     r.revokeRetention().Seconds(),
+		"mode":                 r.mode(),
+		"app_name_template":    r.AppNameTemplate,
+		"developer_first_name": r.DeveloperFirstName,
+		"developer_last_name":  r.DeveloperLastName,
+		"developer_attributes": r.DeveloperAttributes,
 	}
 
 	return respData
 }
+
+func (r *apigeeRole) leaseTTLs(sys logical.SystemView) (time.Duration, time.Duration) {
+	maxTTL := sys.MaxLeaseTTL()
+
+	if r.MaxTTL > 0 && r.MaxTTL < maxTTL {
+		maxTTL = r.MaxTTL
+	}
+
+	ttl := sys.DefaultLeaseTTL()
+
+	if r.TTL > 0 {
+		ttl = r.TTL
+	}
+
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	return ttl, maxTTL
+}
+
+func (r *apigeeRole) mode() string {
+	if r.Mode == "" {
+		return roleModeKey
+	}
+
+	return r.Mode
+}
+
+func (b *apigeeBackend) validateApiProducts(ctx context.Context, s logical.Storage, connection string, orgName string, apiProducts []string) (*logical.Response, error) {
+	client, err := b.getClient(ctx, s, connection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := client.ListApiProducts(ctx, orgName)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing api products: %w", err)
+	}
+
+	if missing := apiProductList(existing).missing(apiProducts); len(missing) > 0 {
+		return logical.ErrorResponse("api_products not found in org %s: %s", orgName, strings.Join(missing, ", ")), nil
+	}
+
+	return nil, nil
+}
+
+type apiProductList []string
+
+// UnmarshalJSON also accepts the JSON-array string that api_products was
+// stored as before it became a list.
+func (p *apiProductList) UnmarshalJSON(data []byte) error {
+	var products []string
+
+	if err := json.Unmarshal(data, &products); err == nil {
+		*p = products
+		return nil
+	}
+
+	var legacy string
+
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*p = parseApiProducts([]string{legacy})
+
+	return nil
+}
+
+func (p apiProductList) missing(products []string) []string {
+	known := make(map[string]bool, len(p))
+
+	for _, product := range p {
+		known[product] = true
+	}
+
+	var missing []string
+
+	for _, product := range products {
+		if !known[product] {
+			missing = append(missing, product)
+		}
+	}
+
+	return missing
+}
+
+func parseApiProducts(raw []string) apiProductList {
+	if len(raw) == 1 && strings.HasPrefix(strings.TrimSpace(raw[0]), "[") {
+		var products []string
+
+		if err := json.Unmarshal([]byte(raw[0]), &products); err == nil {
+			raw = products
+		}
+	}
+
+	products := apiProductList{}
+
+	for _, product := range raw {
+		if product = strings.TrimSpace(product); product != "" {
+			products = append(products, product)
+		}
+	}
+
+	return products
+}
+
+func (r *apigeeRole) revokeMode() string {
+	if r.RevokeMode == "" {
+		return revokeModeDelete
+	}
+
+	return r.RevokeMode
+}
+
+func (r *apigeeRole) revokeRetention() time.Duration {
+	if r.RevokeRetention == 0 {
+		return defaultRevokeRetention
+	}
+
+	return r.RevokeRetention
+}
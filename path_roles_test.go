@@ -2,23 +2,25 @@ package secretsengine
 
 import (
 	"context"
-	"os"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/require"
 )
 
 func TestRoles(t *testing.T) {
-	b, s := getTestBackend(t)
+	b, s, fake := getTestBackendWithFake(t)
+	fake.addOrg("org", "product", "other")
 
 	t.Run("CreateRole", func(t *testing.T) {
 		resp, err := testRoleCreate(t, b, s, map[string]interface{}{
-			"org_name":        os.Getenv(envVarApigeeOrgName),
-			"developer_email": os.Getenv(envVarApigeeDeveloperEmail),
-			"app_name":        os.Getenv(envVarApigeeAppName),
-			"api_products":    os.Getenv(envVarApigeeApiProducts),
+			"org_name":        "org",
+			"developer_email": "developer@example.com",
+			"app_name":        "app",
+			"api_products":    "product,other",
 			"ttl":             "24h",
+			"max_ttl":         "48h",
 		})
 
 		require.Nil(t, err)
@@ -35,12 +37,60 @@ func TestRoles(t *testing.T) {
 		require.NotNil(t, resp)
 		require.NotNil(t, resp.Data)
 
-		require.Contains(t, resp.Data["org_name"], os.Getenv(envVarApigeeOrgName))
-		require.Contains(t, resp.Data["developer_email"], os.Getenv(envVarApigeeDeveloperEmail))
-		require.Contains(t, resp.Data["app_name"], os.Getenv(envVarApigeeAppName))
-		require.Contains(t, resp.Data["api_products"], os.Getenv(envVarApigeeApiProducts))
+		require.Equal(t, "org", resp.Data["org_name"])
+		require.Equal(t, "developer@example.com", resp.Data["developer_email"])
+		require.Equal(t, "app", resp.Data["app_name"])
+		require.Equal(t, []string{"product", "other"}, resp.Data["api_products"])
 
 		require.NotEmpty(t, resp.Data["ttl"])
+		require.NotEmpty(t, resp.Data["max_ttl"])
+	})
+
+	t.Run("UpdateRoleInvalidTTL", func(t *testing.T) {
+		resp, err := testRoleUpdate(t, b, s, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "developer@example.com",
+			"app_name":        "app",
+			"api_products":    "product",
+			"ttl":             "72h",
+		})
+
+		require.Nil(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("UpdateRoleUnknownApiProduct", func(t *testing.T) {
+		resp, err := testRoleUpdate(t, b, s, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "developer@example.com",
+			"app_name":        "app",
+			"api_products":    "product,typo",
+		})
+
+		require.Nil(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "typo")
+	})
+
+	t.Run("UpdateRoleSkipApiProductsValidation", func(t *testing.T) {
+		resp, err := testRoleUpdate(t, b, s, map[string]interface{}{
+			"org_name":                     "org",
+			"developer_email":              "developer@example.com",
+			"app_name":                     "app",
+			"api_products":                 "product,typo",
+			"skip_api_products_validation": true,
+		})
+
+		require.Nil(t, err)
+		require.Nil(t, resp)
+
+		resp, err = testRoleRead(t, b, s)
+
+		require.Nil(t, err)
+		require.Equal(t, []string{"product", "typo"}, resp.Data["api_products"])
+		require.NotContains(t, resp.Data, "skip_api_products_validation")
 	})
 
 	t.Run("DeleteRole", func(t *testing.T) {
@@ -52,6 +102,44 @@ func TestRoles(t *testing.T) {
 	})
 }
 
+func TestRoleLegacyApiProducts(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	err := s.Put(context.Background(), &logical.StorageEntry{
+		Key:   "roles/legacy",
+		Value: []byte(`{"org_name":"org","developer_email":"developer@example.com","app_name":"app","api_products":"[\"product\",\"other\"]"}`),
+	})
+
+	require.Nil(t, err)
+
+	role, err := b.getRole(context.Background(), s, "legacy")
+
+	require.Nil(t, err)
+	require.Equal(t, apiProductList{"product", "other"}, role.ApiProducts)
+
+	require.Equal(t, apiProductList{"product"}, parseApiProducts([]string{`["product"]`}))
+	require.Equal(t, apiProductList{"product", "other"}, parseApiProducts([]string{" product", "", "other "}))
+}
+
+func TestRoleLeaseTTLs(t *testing.T) {
+	sys := &logical.StaticSystemView{
+		DefaultLeaseTTLVal: 1 * time.Hour,
+		MaxLeaseTTLVal:     4 * time.Hour,
+	}
+
+	ttl, maxTTL := (&apigeeRole{}).leaseTTLs(sys)
+	require.Equal(t, 1*time.Hour, ttl)
+	require.Equal(t, 4*time.Hour, maxTTL)
+
+	ttl, maxTTL = (&apigeeRole{TTL: 2 * time.Hour, MaxTTL: 3 * time.Hour}).leaseTTLs(sys)
+	require.Equal(t, 2*time.Hour, ttl)
+	require.Equal(t, 3*time.Hour, maxTTL)
+
+	ttl, maxTTL = (&apigeeRole{TTL: 8 * time.Hour, MaxTTL: 12 * time.Hour}).leaseTTLs(sys)
+	require.Equal(t, 4*time.Hour, ttl)
+	require.Equal(t, 4*time.Hour, maxTTL)
+}
+
 func testRoleCreate(t *testing.T, b *apigeeBackend, s logical.Storage, d map[string]interface{}) (*logical.Response, error) {
 	t.Helper()
 
@@ -73,6 +161,17 @@ func testRoleCreate(t *testing.T, b *apigeeBackend, s logical.Storage, d map[str
 	return resp, nil
 }
 
+func testRoleUpdate(t *testing.T, b *apigeeBackend, s logical.Storage, d map[string]interface{}) (*logical.Response, error) {
+	t.Helper()
+
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test",
+		Data:      d,
+		Storage:   s,
+	})
+}
+
 func testRoleRead(t *testing.T, b *apigeeBackend, s logical.Storage) (*logical.Response, error) {
 	t.Helper()
 
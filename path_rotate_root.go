@@ -0,0 +1,151 @@
+package secretsengine
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathRotateRoot(b *apigeeBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate-root(/" + framework.GenericNameRegex("connection") + ")?",
+		Fields: map[string]*framework.FieldSchema{
+			"connection": {
+				Type:        framework.TypeString,
+				Description: "The name of the connection. If not set, the password of the default connection is rotated",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:                    b.pathRotateRootUpdate,
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+		HelpSynopsis:    pathRotateRootHelpSynopsis,
+		HelpDescription: pathRotateRootHelpDescription,
+	}
+}
+
+func (b *apigeeBackend) pathRotateRootUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	connection := data.Get("connection").(string)
+
+	config, err := getConfig(ctx, req.Storage, connection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return nil, errors.New("config not found during rotate-root operation")
+	}
+
+	if config.Username == "" || config.Password == "" {
+		return logical.ErrorResponse("rotate-root requires username and password in config"), nil
+	}
+
+	client, err := b.getClient(ctx, req.Storage, connection)
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	password, err := b.generatePassword(ctx, config.PasswordPolicy)
+
+	if err != nil {
+		return nil, fmt.Errorf("error generating password: %w", err)
+	}
+
+	err = client.UpdateUserPassword(ctx, config.Username, password)
+
+	if err != nil {
+		return nil, fmt.Errorf("error rotating password: %w", err)
+	}
+
+	config.Password = password
+
+	if err := setConfig(ctx, req.Storage, connection, config); err != nil {
+		b.Logger().Error("password was rotated in Apigee but could not be stored", "username", config.Username, "error", err)
+		return nil, fmt.Errorf("error storing rotated password: %w", err)
+	}
+
+	b.reset(connection)
+
+	return nil, nil
+}
+
+func (b *apigeeBackend) generatePassword(ctx context.Context, policy string) (string, error) {
+	if policy != "" {
+		return b.System().GeneratePasswordFromPolicy(ctx, policy)
+	}
+
+	return generateDefaultPassword()
+}
+
+const defaultPasswordLength = 24
+
+// defaultPasswordCharsets are the character classes of a password generated
+// without password_policy. Each one is used at least once, so that the
+// password meets the complexity rules of Apigee Edge.
+var defaultPasswordCharsets = []string{
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"abcdefghijklmnopqrstuvwxyz",
+	"0123456789",
+	"!#$%*+-.=?@^_~",
+}
+
+func generateDefaultPassword() (string, error) {
+	all := strings.Join(defaultPasswordCharsets, "")
+	password := make([]byte, 0, defaultPasswordLength)
+
+	for len(password) < defaultPasswordLength {
+		charset := all
+
+		if len(password) < len(defaultPasswordCharsets) {
+			charset = defaultPasswordCharsets[len(password)]
+		}
+
+		i, err := randomIndex(len(charset))
+
+		if err != nil {
+			return "", err
+		}
+
+		password = append(password, charset[i])
+	}
+
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+
+		if err != nil {
+			return "", err
+		}
+
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
+
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(i.Int64()), nil
+}
+
+const pathRotateRootHelpSynopsis = `Rotate the Apigee Management API password.`
+
+const pathRotateRootHelpDescription = `This path changes the password of the Apigee Edge management user in config,
+or in config/<connection> for rotate-root/<connection>, to a value generated by
+Vault, optionally from password_policy, and stores the new password. The new
+password is never returned.`
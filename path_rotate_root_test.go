@@ -0,0 +1,99 @@
+package secretsengine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateRoot(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	var rotated string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+
+		if !ok || username != "admin@example.com" || password != "initial" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/v1/users/admin@example.com", r.URL.Path)
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		rotated = body["password"]
+	}))
+	defer srv.Close()
+
+	t.Run("RequiresPassword", func(t *testing.T) {
+		err := testConfigCreate(t, b, s, map[string]interface{}{
+			"host":              srv.URL,
+			"oauth_token":       "token",
+			"verify_connection": false,
+		})
+
+		require.NoError(t, err)
+
+		resp, err := testRotateRoot(b, s)
+
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("RotateRoot", func(t *testing.T) {
+		err := testConfigDelete(t, b, s)
+
+		require.NoError(t, err)
+
+		err = testConfigCreate(t, b, s, map[string]interface{}{
+			"host":     srv.URL,
+			"username": "admin@example.com",
+			"password": "initial",
+
+			"verify_connection": false,
+		})
+
+		require.NoError(t, err)
+
+		resp, err := testRotateRoot(b, s)
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.NotEmpty(t, rotated)
+
+		config, err := getConfig(context.Background(), s, "")
+
+		require.NoError(t, err)
+		require.Equal(t, rotated, config.Password)
+	})
+}
+
+func TestGenerateDefaultPassword(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		password, err := generateDefaultPassword()
+
+		require.NoError(t, err)
+		require.Len(t, password, defaultPasswordLength)
+
+		for _, charset := range defaultPasswordCharsets {
+			require.True(t, strings.ContainsAny(password, charset), "%q has no character of %q", password, charset)
+		}
+	}
+}
+
+func testRotateRoot(b logical.Backend, s logical.Storage) (*logical.Response, error) {
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "rotate-root",
+		Storage:   s,
+	})
+}
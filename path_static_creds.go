@@ -0,0 +1,61 @@
+package secretsengine
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathStaticCredentials(b *apigeeBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-creds/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the static role",
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathStaticCredentialsRead,
+			},
+		},
+		HelpSynopsis:    pathStaticCredentialsHelpSyn,
+		HelpDescription: pathStaticCredentialsHelpDesc,
+	}
+}
+
+func (b *apigeeBackend) pathStaticCredentialsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := getStaticRole(ctx, req.Storage, d.Get("name").(string))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return logical.ErrorResponse("unknown static role: %s", d.Get("name").(string)), nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"org_name":            role.OrgName,
+			"developer_email":     role.DeveloperEmail,
+			"app_name":            role.AppName,
+			"api_products":        []string(role.ApiProducts),
+			"key":                 role.Key,
+			"secret":              role.Secret,
+			"credentials":         role.Credentials,
+			"last_vault_rotation": role.LastVaultRotation,
+			"rotation_period":     role.RotationPeriod.Seconds(),
+			"ttl":                 time.Until(role.nextVaultRotation()).Truncate(time.Second).Seconds(),
+		},
+	}, nil
+}
+
+const pathStaticCredentialsHelpSyn = `Read the current Apigee credentials of a Vault static role.`
+
+const pathStaticCredentialsHelpDesc = `Read the current Apigee credentials of a Vault static role. The credentials
+are rotated every rotation_period; ttl is the time left until the next rotation.`
@@ -0,0 +1,445 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	staticRoleStoragePrefix = "static-roles/"
+
+	pathStaticRoleHelpSynopsis    = `Manages static roles for rotating long-lived Apigee credentials.`
+	pathStaticRoleHelpDescription = `This path manages Vault static roles, which bind to an existing Apigee developer app
+and rotate a key on it every rotation_period. The previous key stays valid for
+overlap_period after each rotation.`
+
+	pathStaticRoleListHelpSynopsis    = `Lists static roles for rotating long-lived Apigee credentials.`
+	pathStaticRoleListHelpDescription = `This path lists Vault static roles for rotating long-lived Apigee credentials.`
+)
+
+type apigeeStaticRole struct {
+	Connection     string         `json:"connection"`
+	OrgName        string         `json:"org_name"`
+	DeveloperEmail string         `json:"developer_email"`
+	AppName        string         `json:"app_name"`
+	ApiProducts    apiProductList `json:"api_products"`
+	RotationPeriod time.Duration  `json:"rotation_period"`
+	OverlapPeriod  time.Duration  `json:"overlap_period"`
+
+	Key               string    `json:"key"`
+	Secret            string    `json:"secret"`
+	Credentials       string    `json:"credentials"`
+	LastVaultRotation time.Time `json:"last_vault_rotation"`
+
+	PreviousKey       string    `json:"previous_key"`
+	PreviousKeyExpiry time.Time `json:"previous_key_expiry"`
+}
+
+func pathStaticRoles(b *apigeeBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "static-roles/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "The static role name",
+					Required:    true,
+				},
+				"connection": {
+					Type:        framework.TypeString,
+					Description: "The name of the connection at config/<connection> used to rotate the key. If not set, the connection at config is used. Cannot be changed after creation",
+				},
+				"org_name": {
+					Type:        framework.TypeString,
+					Description: "The org_name for the Apigee Management API",
+					Required:    true,
+				},
+				"developer_email": {
+					Type:        framework.TypeString,
+					Description: "The developer_email for the Apigee Management API",
+					Required:    true,
+				},
+				"app_name": {
+					Type:        framework.TypeString,
+					Description: "The app_name of the existing developer app for the Apigee Management API",
+					Required:    true,
+				},
+				"api_products": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma-separated list of the API products the key is approved for",
+					Required:    true,
+				},
+				"skip_api_products_validation": {
+					Type:        framework.TypeBool,
+					Description: "Skip checking that api_products exist in org_name. Not stored with the static role",
+				},
+				"rotation_period": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Period after which the key is rotated",
+					Required:    true,
+				},
+				"overlap_period": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Period the previous key stays valid after a rotation. Must be less than rotation_period",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesRead,
+				},
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesWrite,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesWrite,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesDelete,
+				},
+			},
+			ExistenceCheck:  b.pathRolesExistenceCheck,
+			HelpSynopsis:    pathStaticRoleHelpSynopsis,
+			HelpDescription: pathStaticRoleHelpDescription,
+		},
+		{
+			Pattern: "static-roles/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathStaticRolesList,
+				},
+			},
+			HelpSynopsis:    pathStaticRoleListHelpSynopsis,
+			HelpDescription: pathStaticRoleListHelpDescription,
+		},
+	}
+}
+
+func (b *apigeeBackend) pathStaticRolesWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.staticRoleLock.Lock()
+	defer b.staticRoleLock.Unlock()
+
+	role, err := getStaticRole(ctx, req.Storage, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	createOperation := role == nil
+
+	if createOperation {
+		role = &apigeeStaticRole{}
+	}
+
+	if connection, ok := d.GetOk("connection"); ok {
+		if !createOperation && connection.(string) != role.Connection {
+			return logical.ErrorResponse("connection cannot be changed on an existing static role"), nil
+		}
+
+		role.Connection = connection.(string)
+	}
+
+	bindings := map[string]*string{
+		"org_name":        &role.OrgName,
+		"developer_email": &role.DeveloperEmail,
+		"app_name":        &role.AppName,
+	}
+
+	for field, value := range bindings {
+		v, ok := d.GetOk(field)
+
+		if !ok {
+			if createOperation {
+				return logical.ErrorResponse("missing %s in static role", field), nil
+			}
+			continue
+		}
+
+		if !createOperation && v.(string) != *value {
+			return logical.ErrorResponse("%s cannot be changed on an existing static role", field), nil
+		}
+
+		*value = v.(string)
+	}
+
+	config, err := getConfig(ctx, req.Storage, role.Connection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config != nil {
+		if err := config.checkAllowed(role.OrgName, role.DeveloperEmail, role.AppName); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if api_products, ok := d.GetOk("api_products"); ok {
+		role.ApiProducts = parseApiProducts(api_products.([]string))
+
+		if len(role.ApiProducts) > 0 && !d.Get("skip_api_products_validation").(bool) {
+			if resp, err := b.validateApiProducts(ctx, req.Storage, role.Connection, role.OrgName, role.ApiProducts); resp != nil || err != nil {
+				return resp, err
+			}
+		}
+	}
+
+	if len(role.ApiProducts) == 0 {
+		return logical.ErrorResponse("missing api_products in static role"), nil
+	}
+
+	if rotation_period, ok := d.GetOk("rotation_period"); ok {
+		role.RotationPeriod = time.Duration(rotation_period.(int)) * time.Second
+	} else if createOperation {
+		return logical.ErrorResponse("missing rotation_period in static role"), nil
+	}
+
+	if overlap_period, ok := d.GetOk("overlap_period"); ok {
+		role.OverlapPeriod = time.Duration(overlap_period.(int)) * time.Second
+	}
+
+	if role.RotationPeriod < time.Minute {
+		return logical.ErrorResponse("rotation_period must be at least 1 minute"), nil
+	}
+
+	if role.OverlapPeriod >= role.RotationPeriod {
+		return logical.ErrorResponse("overlap_period must be less than rotation_period"), nil
+	}
+
+	if createOperation {
+		return nil, b.rotateStaticRole(ctx, req.Storage, name, role)
+	}
+
+	return nil, setStaticRole(ctx, req.Storage, name, role)
+}
+
+func (b *apigeeBackend) pathStaticRolesRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := getStaticRole(ctx, req.Storage, d.Get("name").(string))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: role.toResponseData(),
+	}, nil
+}
+
+func (b *apigeeBackend) pathStaticRolesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	b.staticRoleLock.Lock()
+	defer b.staticRoleLock.Unlock()
+
+	role, err := getStaticRole(ctx, req.Storage, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if role == nil {
+		return nil, nil
+	}
+
+	client, err := b.getClient(ctx, req.Storage, role.Connection)
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting client: %w", err)
+	}
+
+	for _, key := range []string{role.PreviousKey, role.Key} {
+		if key == "" {
+			continue
+		}
+
+		if err := deleteCredentials(ctx, client, role.OrgName, role.DeveloperEmail, role.AppName, key); err != nil && !isNotFound(err) {
+			return nil, fmt.Errorf("error deleting credentials: %w", err)
+		}
+	}
+
+	if err := req.Storage.Delete(ctx, staticRoleStoragePrefix+name); err != nil {
+		return nil, fmt.Errorf("error deleting static role: %w", err)
+	}
+
+	return nil, nil
+}
+
+func (b *apigeeBackend) pathStaticRolesList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, staticRoleStoragePrefix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *apigeeBackend) rotateStaticRole(ctx context.Context, s logical.Storage, name string, role *apigeeStaticRole) error {
+	client, err := b.getClient(ctx, s, role.Connection)
+
+	if err != nil {
+		return fmt.Errorf("error getting client: %w", err)
+	}
+
+	if role.PreviousKey != "" {
+		if err := deleteCredentials(ctx, client, role.OrgName, role.DeveloperEmail, role.AppName, role.PreviousKey); err != nil && !isNotFound(err) {
+			return fmt.Errorf("error deleting previous credentials: %w", err)
+		}
+
+		role.PreviousKey = ""
+		role.PreviousKeyExpiry = time.Time{}
+	}
+
+	if err := b.checkAllowed(ctx, s, role.Connection, role.OrgName, role.DeveloperEmail, role.AppName); err != nil {
+		return err
+	}
+
+	token, err := createCredentials(ctx, client, role.OrgName, role.DeveloperEmail, role.AppName, role.ApiProducts, nil, nil, int((role.RotationPeriod + role.OverlapPeriod).Seconds()))
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	if role.Key != "" {
+		role.PreviousKey = role.Key
+		role.PreviousKeyExpiry = now.Add(role.OverlapPeriod)
+	}
+
+	role.Key = token.Key
+	role.Secret = token.Secret
+	role.Credentials = token.Credentials
+	role.LastVaultRotation = now
+
+	if err := setStaticRole(ctx, s, name, role); err != nil {
+		return err
+	}
+
+	if role.PreviousKey != "" && role.OverlapPeriod == 0 {
+		return b.expireStaticRolePreviousKey(ctx, s, name, role)
+	}
+
+	return nil
+}
+
+func (b *apigeeBackend) expireStaticRolePreviousKey(ctx context.Context, s logical.Storage, name string, role *apigeeStaticRole) error {
+	client, err := b.getClient(ctx, s, role.Connection)
+
+	if err != nil {
+		return fmt.Errorf("error getting client: %w", err)
+	}
+
+	if err := deleteCredentials(ctx, client, role.OrgName, role.DeveloperEmail, role.AppName, role.PreviousKey); err != nil && !isNotFound(err) {
+		return fmt.Errorf("error deleting previous credentials: %w", err)
+	}
+
+	role.PreviousKey = ""
+	role.PreviousKeyExpiry = time.Time{}
+
+	return setStaticRole(ctx, s, name, role)
+}
+
+func (b *apigeeBackend) rotateStaticRoles(ctx context.Context, s logical.Storage) error {
+	b.staticRoleLock.Lock()
+	defer b.staticRoleLock.Unlock()
+
+	names, err := s.List(ctx, staticRoleStoragePrefix)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, name := range names {
+		role, err := getStaticRole(ctx, s, name)
+
+		if err != nil || role == nil {
+			b.Logger().Error("error reading static role", "name", name, "error", err)
+			continue
+		}
+
+		if role.PreviousKey != "" && !now.Before(role.PreviousKeyExpiry) {
+			if err := b.expireStaticRolePreviousKey(ctx, s, name, role); err != nil {
+				b.Logger().Error("error expiring previous key of static role", "name", name, "error", err)
+			}
+		}
+
+		if now.Before(role.nextVaultRotation()) {
+			continue
+		}
+
+		if err := b.rotateStaticRole(ctx, s, name, role); err != nil {
+			b.Logger().Error("error rotating static role", "name", name, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func setStaticRole(ctx context.Context, s logical.Storage, name string, role *apigeeStaticRole) error {
+	entry, err := logical.StorageEntryJSON(staticRoleStoragePrefix+name, role)
+
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		return fmt.Errorf("failed to create storage entry for static role")
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func getStaticRole(ctx context.Context, s logical.Storage, name string) (*apigeeStaticRole, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing static role name")
+	}
+
+	entry, err := s.Get(ctx, staticRoleStoragePrefix+name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	var role apigeeStaticRole
+
+	if err := entry.DecodeJSON(&role); err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+func (r *apigeeStaticRole) nextVaultRotation() time.Time {
+	return r.LastVaultRotation.Add(r.RotationPeriod)
+}
+
+func (r *apigeeStaticRole) toResponseData() map[string]interface{} {
+	respData := map[string]interface{}{
+		"connection":          r.Connection,
+		"org_name":            r.OrgName,
+		"developer_email":     r.DeveloperEmail,
+		"app_name":            r.AppName,
+		"api_products":        []string(r.ApiProducts),
+		"rotation_period":     r.RotationPeriod.Seconds(),
+		"overlap_period":      r.OverlapPeriod.Seconds(),
+		"last_vault_rotation": r.LastVaultRotation,
+	}
+
+	return respData
+}
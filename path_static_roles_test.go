@@ -0,0 +1,208 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticRoles(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	t.Run("CreateStaticRoleInvalidOverlap", func(t *testing.T) {
+		resp, err := testStaticRoleWrite(b, s, logical.CreateOperation, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "dev@example.com",
+			"app_name":        "app",
+			"api_products":    "product",
+			"rotation_period": "1h",
+			"overlap_period":  "2h",
+		})
+
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("CreateStaticRole", func(t *testing.T) {
+		resp, err := testStaticRoleWrite(b, s, logical.CreateOperation, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "dev@example.com",
+			"app_name":        "app",
+			"api_products":    "product",
+			"rotation_period": "1h",
+			"overlap_period":  "10m",
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.True(t, fake.hasKey("org", "dev@example.com", "app", "key-1"))
+	})
+
+	t.Run("ReadStaticCreds", func(t *testing.T) {
+		resp := testStaticCredsRead(t, b, s)
+
+		require.Equal(t, "key-1", resp.Data["key"])
+		require.Equal(t, "secret-1", resp.Data["secret"])
+
+		key := fake.getKey("org", "dev@example.com", "app", "key-1")
+		require.WithinDuration(t, time.Now().Add(70*time.Minute), key.expiresAt, time.Minute)
+		require.NotEmpty(t, resp.Data["credentials"])
+	})
+
+	t.Run("UpdateStaticRoleBinding", func(t *testing.T) {
+		resp, err := testStaticRoleWrite(b, s, logical.UpdateOperation, map[string]interface{}{
+			"app_name": "other",
+		})
+
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("RotateStaticRole", func(t *testing.T) {
+		role, err := getStaticRole(context.Background(), s, "test")
+		require.NoError(t, err)
+
+		role.LastVaultRotation = time.Now().Add(-2 * time.Hour)
+		require.NoError(t, setStaticRole(context.Background(), s, "test", role))
+
+		require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+
+		resp := testStaticCredsRead(t, b, s)
+
+		require.Equal(t, "key-2", resp.Data["key"])
+		require.True(t, fake.hasKey("org", "dev@example.com", "app", "key-1"))
+		require.True(t, fake.hasKey("org", "dev@example.com", "app", "key-2"))
+	})
+
+	t.Run("ExpirePreviousKey", func(t *testing.T) {
+		role, err := getStaticRole(context.Background(), s, "test")
+		require.NoError(t, err)
+		require.Equal(t, "key-1", role.PreviousKey)
+
+		role.PreviousKeyExpiry = time.Now().Add(-time.Minute)
+		require.NoError(t, setStaticRole(context.Background(), s, "test", role))
+
+		require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+
+		require.False(t, fake.hasKey("org", "dev@example.com", "app", "key-1"))
+		require.True(t, fake.hasKey("org", "dev@example.com", "app", "key-2"))
+	})
+
+	t.Run("DeleteStaticRole", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "static-roles/test",
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.False(t, fake.hasKey("org", "dev@example.com", "app", "key-2"))
+	})
+}
+
+func TestStaticRolesMissingPreviousKey(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	resp, err := testStaticRoleWrite(b, s, logical.CreateOperation, map[string]interface{}{
+		"org_name":        "org",
+		"developer_email": "dev@example.com",
+		"app_name":        "app",
+		"api_products":    "product",
+		"rotation_period": "1h",
+		"overlap_period":  "10m",
+	})
+
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	rotate := func(t *testing.T) {
+		role, err := getStaticRole(context.Background(), s, "test")
+		require.NoError(t, err)
+
+		role.LastVaultRotation = time.Now().Add(-2 * time.Hour)
+		require.NoError(t, setStaticRole(context.Background(), s, "test", role))
+
+		require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+	}
+
+	rotate(t)
+
+	t.Run("RotateWithoutPreviousKey", func(t *testing.T) {
+		require.NoError(t, fake.DeleteCredentials(context.Background(), "org", "dev@example.com", "app", "key-1"))
+
+		rotate(t)
+
+		role, err := getStaticRole(context.Background(), s, "test")
+		require.NoError(t, err)
+		require.Equal(t, "key-3", role.Key)
+		require.Equal(t, "key-2", role.PreviousKey)
+	})
+
+	t.Run("ExpireWithoutPreviousKey", func(t *testing.T) {
+		require.NoError(t, fake.DeleteCredentials(context.Background(), "org", "dev@example.com", "app", "key-2"))
+
+		role, err := getStaticRole(context.Background(), s, "test")
+		require.NoError(t, err)
+
+		role.PreviousKeyExpiry = time.Now().Add(-time.Minute)
+		require.NoError(t, setStaticRole(context.Background(), s, "test", role))
+
+		require.NoError(t, b.periodicFunc(context.Background(), &logical.Request{Storage: s}))
+
+		role, err = getStaticRole(context.Background(), s, "test")
+		require.NoError(t, err)
+		require.Empty(t, role.PreviousKey)
+	})
+
+	t.Run("DeleteWithoutKeys", func(t *testing.T) {
+		require.NoError(t, fake.DeleteCredentials(context.Background(), "org", "dev@example.com", "app", "key-3"))
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "static-roles/test",
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		role, err := getStaticRole(context.Background(), s, "test")
+		require.NoError(t, err)
+		require.Nil(t, role)
+	})
+}
+
+func testStaticRoleWrite(b logical.Backend, s logical.Storage, op logical.Operation, d map[string]interface{}) (*logical.Response, error) {
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: op,
+		Path:      "static-roles/test",
+		Data:      d,
+		Storage:   s,
+	})
+}
+
+func testStaticCredsRead(t *testing.T, b logical.Backend, s logical.Storage) *logical.Response {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-creds/test",
+		Storage:   s,
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	require.False(t, resp.IsError())
+
+	return resp
+}
@@ -0,0 +1,172 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBaseDelay   = time.Second
+	defaultRetryMaxDelay    = 30 * time.Second
+	defaultRetryJitter      = 0.2
+)
+
+type idempotentKey struct{}
+
+// withIdempotent marks requests made with ctx as safe to retry even when
+// their method, such as POST, is not idempotent by definition.
+func withIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	idempotent, _ := req.Context().Value(idempotentKey{}).(bool)
+
+	return idempotent
+}
+
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	jitter      float64
+}
+
+// retryTransport retries Apigee Management API requests that failed with a
+// transient error. Rate limited (429) requests were not processed, so they
+// are always retried. Network errors and 500, 502, 503, and 504 responses may
+// have been processed, so they are only retried for idempotent requests.
+// Every other response is permanent and returned as is.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy retryPolicy
+}
+
+func newRetryTransport(base http.RoundTripper, policy retryPolicy) *retryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &retryTransport{base: base, policy: policy}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	idempotent := isIdempotent(req)
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+
+			if err != nil {
+				return nil, err
+			}
+
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		res, err := t.base.RoundTrip(req)
+
+		if attempt >= t.policy.maxAttempts || !retryable(res, err, idempotent) {
+			if err != nil && attempt > 1 {
+				return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+
+			return res, err
+		}
+
+		delay := t.policy.delay(attempt)
+
+		if res != nil {
+			if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				if retryAfter > t.policy.maxDelay {
+					return res, nil
+				}
+
+				delay = retryAfter
+			}
+
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func retryable(res *http.Response, err error, idempotent bool) bool {
+	if err != nil {
+		return idempotent
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	default:
+		return false
+	}
+}
+
+// delay returns the exponential backoff before the retry following attempt,
+// reduced by a random fraction of up to jitter.
+func (p retryPolicy) delay(attempt int) time.Duration {
+	delay := p.baseDelay << (attempt - 1)
+
+	if delay <= 0 || delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+
+	if p.jitter > 0 {
+		delay -= time.Duration(p.jitter * rand.Float64() * float64(delay))
+	}
+
+	return delay
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+
+	if err != nil {
+		return 0, false
+	}
+
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+
+	return 0, true
+}
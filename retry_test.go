@@ -0,0 +1,179 @@
+package secretsengine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryTransport(t *testing.T) {
+	newTestClient := func(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, attempt int32)) (*apigeeClient, *int32) {
+		var attempts int32
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r, atomic.AddInt32(&attempts, 1))
+		}))
+		t.Cleanup(srv.Close)
+
+		client, err := newClient(context.Background(), &apigeeConfig{
+			Host:             srv.URL,
+			OAuthToken:       "token",
+			RetryMaxAttempts: 3,
+			RetryBaseDelay:   time.Millisecond,
+			RetryMaxDelay:    10 * time.Millisecond,
+			RetryJitter:      0.5,
+		})
+
+		require.NoError(t, err)
+
+		return client, &attempts
+	}
+
+	t.Run("RetryIdempotentOnServerError", func(t *testing.T) {
+		client, attempts := newTestClient(t, func(w http.ResponseWriter, r *http.Request, attempt int32) {
+			if attempt < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.Write([]byte(`["product"]`))
+		})
+
+		products, err := client.ListApiProducts(context.Background(), "org")
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"product"}, products)
+		require.EqualValues(t, 3, *attempts)
+	})
+
+	t.Run("RetryIdempotentPost", func(t *testing.T) {
+		client, attempts := newTestClient(t, func(w http.ResponseWriter, r *http.Request, attempt int32) {
+			if attempt == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			require.Equal(t, "approve", r.URL.Query().Get("action"))
+		})
+
+		err := client.ApproveCredentials(context.Background(), "org", "dev@example.com", "app", "key")
+
+		require.NoError(t, err)
+		require.EqualValues(t, 2, *attempts)
+	})
+
+	t.Run("NoRetryNonIdempotentOnServerError", func(t *testing.T) {
+		client, attempts := newTestClient(t, func(w http.ResponseWriter, r *http.Request, attempt int32) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+
+		_, err := client.CreateApp(context.Background(), "org", "dev@example.com", "app", []string{"product"}, nil, nil, 60)
+
+		require.Error(t, err)
+		require.EqualValues(t, 1, *attempts)
+	})
+
+	t.Run("RetryRateLimitedWithBody", func(t *testing.T) {
+		var bodies []int64
+
+		client, attempts := newTestClient(t, func(w http.ResponseWriter, r *http.Request, attempt int32) {
+			bodies = append(bodies, r.ContentLength)
+
+			if attempt == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		})
+
+		err := client.CreateDeveloper(context.Background(), "org", "dev@example.com", "First", "Last", nil)
+
+		require.NoError(t, err)
+		require.EqualValues(t, 2, *attempts)
+		require.Equal(t, bodies[0], bodies[1])
+	})
+
+	t.Run("RetryAfterExceedsMaxDelay", func(t *testing.T) {
+		client, attempts := newTestClient(t, func(w http.ResponseWriter, r *http.Request, attempt int32) {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+
+		err := client.DeleteCredentials(context.Background(), "org", "dev@example.com", "app", "key")
+
+		require.Error(t, err)
+		require.EqualValues(t, 1, *attempts)
+	})
+
+	t.Run("GiveUpAfterMaxAttempts", func(t *testing.T) {
+		client, attempts := newTestClient(t, func(w http.ResponseWriter, r *http.Request, attempt int32) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		err := client.DeleteApp(context.Background(), "org", "dev@example.com", "app")
+
+		require.Equal(t, http.StatusInternalServerError, statusCode(err))
+		require.EqualValues(t, 3, *attempts)
+	})
+
+	t.Run("NoRetryPermanentError", func(t *testing.T) {
+		client, attempts := newTestClient(t, func(w http.ResponseWriter, r *http.Request, attempt int32) {
+			w.WriteHeader(http.StatusBadRequest)
+		})
+
+		_, err := client.ListApiProducts(context.Background(), "org")
+
+		require.Equal(t, http.StatusBadRequest, statusCode(err))
+		require.EqualValues(t, 1, *attempts)
+	})
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := retryPolicy{baseDelay: time.Second, maxDelay: 5 * time.Second}
+
+	require.Equal(t, time.Second, policy.delay(1))
+	require.Equal(t, 2*time.Second, policy.delay(2))
+	require.Equal(t, 4*time.Second, policy.delay(3))
+	require.Equal(t, 5*time.Second, policy.delay(4))
+
+	policy.jitter = 0.5
+
+	for i := 0; i < 10; i++ {
+		delay := policy.delay(2)
+
+		require.GreaterOrEqual(t, delay, time.Second)
+		require.LessOrEqual(t, delay, 2*time.Second)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	delay, ok := parseRetryAfter("7", now)
+
+	require.True(t, ok)
+	require.Equal(t, 7*time.Second, delay)
+
+	delay, ok = parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now)
+
+	require.True(t, ok)
+	require.Equal(t, time.Minute, delay)
+
+	delay, ok = parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now)
+
+	require.True(t, ok)
+	require.Zero(t, delay)
+
+	_, ok = parseRetryAfter("soon", now)
+
+	require.False(t, ok)
+
+	_, ok = parseRetryAfter("", now)
+
+	require.False(t, ok)
+}
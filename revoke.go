@@ -0,0 +1,130 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	revokeModeDelete = "delete"
+	revokeModeRevoke = "revoke"
+	revokeModeExpire = "expire"
+
+	defaultRevokeRetention = 30 * 24 * time.Hour
+
+	revokedCredentialsStoragePrefix = "revoked-credentials/"
+)
+
+// revokedCredentials records credentials that were revoked instead of deleted,
+// so they can be deleted once the retention period has passed.
+type revokedCredentials struct {
+	Connection     string    `json:"connection"`
+	Mode           string    `json:"mode"`
+	OrgName        string    `json:"org_name"`
+	DeveloperEmail string    `json:"developer_email"`
+	AppName        string    `json:"app_name"`
+	Key            string    `json:"key"`
+	DeleteAfter    time.Time `json:"delete_after"`
+}
+
+func (b *apigeeBackend) softRevokeCredentials(ctx context.Context, s logical.Storage, client *apigeeClient, connection string, mode string, revokeMode string, retention time.Duration, token *apigeeToken) error {
+	var err error
+
+	switch revokeMode {
+	// Apigee cannot change the expiry of an existing key, so expiring a key
+	// revokes it, which takes effect immediately as well.
+	case revokeModeRevoke, revokeModeExpire:
+		err = client.RevokeCredentials(ctx, token.OrgName, token.DeveloperEmail, token.AppName, token.Key)
+	default:
+		return fmt.Errorf("unknown revoke mode %q", revokeMode)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error setting key to %s: %w", revokeMode, err)
+	}
+
+	id, err := uuid.GenerateUUID()
+
+	if err != nil {
+		return err
+	}
+
+	entry, err := logical.StorageEntryJSON(revokedCredentialsStoragePrefix+id, &revokedCredentials{
+		Connection:     connection,
+		Mode:           mode,
+		OrgName:        token.OrgName,
+		DeveloperEmail: token.DeveloperEmail,
+		AppName:        token.AppName,
+		Key:            token.Key,
+		DeleteAfter:    time.Now().Add(retention),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.Put(ctx, entry); err != nil {
+		return fmt.Errorf("error storing revoked credentials: %w", err)
+	}
+
+	return nil
+}
+
+func (b *apigeeBackend) cleanupRevokedCredentials(ctx context.Context, s logical.Storage) error {
+	ids, err := s.List(ctx, revokedCredentialsStoragePrefix)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, id := range ids {
+		raw, err := s.Get(ctx, revokedCredentialsStoragePrefix+id)
+
+		if err != nil || raw == nil {
+			b.Logger().Error("error reading revoked credentials", "id", id, "error", err)
+			continue
+		}
+
+		var entry revokedCredentials
+
+		if err := raw.DecodeJSON(&entry); err != nil {
+			b.Logger().Error("error decoding revoked credentials", "id", id, "error", err)
+			continue
+		}
+
+		if now.Before(entry.DeleteAfter) {
+			continue
+		}
+
+		client, err := b.getClient(ctx, s, entry.Connection)
+
+		if err != nil {
+			b.Logger().Error("error getting client for revoked credentials", "connection", entry.Connection, "error", err)
+			continue
+		}
+
+		err = deleteLeaseCredentials(ctx, client, entry.Mode, &apigeeToken{
+			OrgName:        entry.OrgName,
+			DeveloperEmail: entry.DeveloperEmail,
+			AppName:        entry.AppName,
+			Key:            entry.Key,
+		})
+
+		if err != nil && !isNotFound(err) {
+			b.Logger().Error("error deleting revoked credentials", "key", entry.Key, "error", err)
+			continue
+		}
+
+		if err := s.Delete(ctx, revokedCredentialsStoragePrefix+id); err != nil {
+			b.Logger().Error("error removing revoked credentials entry", "id", id, "error", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeMode(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	issue := func(t *testing.T, data map[string]interface{}) *logical.Response {
+		_, err := testRoleUpdate(t, b, s, data)
+
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		return resp
+	}
+
+	revoke := func(t *testing.T, resp *logical.Response) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   s,
+			Secret:    resp.Secret,
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	}
+
+	t.Run("Revoke", func(t *testing.T) {
+		resp := issue(t, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "dev@example.com",
+			"app_name":        "app",
+			"api_products":    "product",
+			"revoke_mode":     revokeModeRevoke,
+		})
+
+		revoke(t, resp)
+
+		key := fake.getKey("org", "dev@example.com", "app", resp.Data["key"].(string))
+
+		require.NotNil(t, key)
+		require.True(t, key.revoked)
+
+		require.NoError(t, b.cleanupRevokedCredentials(context.Background(), s))
+		require.True(t, fake.hasKey("org", "dev@example.com", "app", resp.Data["key"].(string)))
+
+		expireRevokedCredentials(t, s)
+
+		require.NoError(t, b.cleanupRevokedCredentials(context.Background(), s))
+		require.False(t, fake.hasKey("org", "dev@example.com", "app", resp.Data["key"].(string)))
+
+		ids, err := s.List(context.Background(), revokedCredentialsStoragePrefix)
+
+		require.NoError(t, err)
+		require.Empty(t, ids)
+	})
+
+	t.Run("ExpireApp", func(t *testing.T) {
+		resp := issue(t, map[string]interface{}{
+			"org_name":         "org",
+			"developer_email":  "dev@example.com",
+			"api_products":     "product",
+			"mode":             roleModeApp,
+			"revoke_mode":      revokeModeExpire,
+			"revoke_retention": "1h",
+		})
+
+		appName := resp.Data["app_name"].(string)
+
+		revoke(t, resp)
+
+		key := fake.getKey("org", "dev@example.com", appName, resp.Data["key"].(string))
+
+		require.NotNil(t, key)
+		require.True(t, key.revoked)
+		require.NoError(t, b.cleanupRevokedCredentials(context.Background(), s))
+		require.Contains(t, fake.apps("org", "dev@example.com"), appName)
+
+		expireRevokedCredentials(t, s)
+
+		require.NoError(t, b.cleanupRevokedCredentials(context.Background(), s))
+		require.NotContains(t, fake.apps("org", "dev@example.com"), appName)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		resp := issue(t, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "dev@example.com",
+			"app_name":        "app",
+			"api_products":    "product",
+			"mode":            roleModeKey,
+			"revoke_mode":     revokeModeDelete,
+		})
+
+		revoke(t, resp)
+
+		require.False(t, fake.hasKey("org", "dev@example.com", "app", resp.Data["key"].(string)))
+
+		ids, err := s.List(context.Background(), revokedCredentialsStoragePrefix)
+
+		require.NoError(t, err)
+		require.Empty(t, ids)
+	})
+}
+
+func expireRevokedCredentials(t *testing.T, s logical.Storage) {
+	t.Helper()
+
+	ids, err := s.List(context.Background(), revokedCredentialsStoragePrefix)
+
+	require.NoError(t, err)
+	require.NotEmpty(t, ids)
+
+	for _, id := range ids {
+		raw, err := s.Get(context.Background(), revokedCredentialsStoragePrefix+id)
+
+		require.NoError(t, err)
+
+		var entry revokedCredentials
+
+		require.NoError(t, raw.DecodeJSON(&entry))
+
+		entry.DeleteAfter = time.Now().Add(-time.Second)
+
+		updated, err := logical.StorageEntryJSON(revokedCredentialsStoragePrefix+id, &entry)
+
+		require.NoError(t, err)
+		require.NoError(t, s.Put(context.Background(), updated))
+	}
+}
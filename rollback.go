@@ -0,0 +1,76 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	walTypeCredentials = "credentials"
+
+	walRollbackMinAge = 5 * time.Minute
+)
+
+// walCredentials records a key, app, or developer being created in Apigee. In key mode an
+// entry without a key is written before the key is created and replaced by one
+// with the key as soon as Apigee returns it.
+type walCredentials struct {
+	Connection     string `json:"connection" mapstructure:"connection"`
+	Mode           string `json:"mode" mapstructure:"mode"`
+	OrgName        string `json:"org_name" mapstructure:"org_name"`
+	DeveloperEmail string `json:"developer_email" mapstructure:"developer_email"`
+	AppName        string `json:"app_name" mapstructure:"app_name"`
+	Key            string `json:"key" mapstructure:"key"`
+}
+
+func (b *apigeeBackend) walRollback(ctx context.Context, req *logical.Request, kind string, data interface{}) error {
+	switch kind {
+	case walTypeCredentials:
+		return b.rollbackCredentials(ctx, req, data)
+	default:
+		return fmt.Errorf("unknown WAL entry type %q", kind)
+	}
+}
+
+func (b *apigeeBackend) rollbackCredentials(ctx context.Context, req *logical.Request, data interface{}) error {
+	var entry walCredentials
+
+	if err := mapstructure.Decode(data, &entry); err != nil {
+		return err
+	}
+
+	if (entry.Mode == "" || entry.Mode == roleModeKey) && entry.Key == "" {
+		b.Logger().Warn("credentials creation was interrupted before a key was returned", "org_name", entry.OrgName, "developer_email", entry.DeveloperEmail, "app_name", entry.AppName)
+		return nil
+	}
+
+	client, err := b.getClient(ctx, req.Storage, entry.Connection)
+
+	if err != nil {
+		return fmt.Errorf("error getting client: %w", err)
+	}
+
+	err = deleteLeaseCredentials(ctx, client, entry.Mode, &apigeeToken{
+		OrgName:        entry.OrgName,
+		DeveloperEmail: entry.DeveloperEmail,
+		AppName:        entry.AppName,
+		Key:            entry.Key,
+	})
+
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("error deleting credentials: %w", err)
+	}
+
+	return nil
+}
+
+func (b *apigeeBackend) deleteWAL(ctx context.Context, s logical.Storage, id string) {
+	if err := framework.DeleteWAL(ctx, s, id); err != nil {
+		b.Logger().Warn("error deleting WAL entry", "id", id, "error", err)
+	}
+}
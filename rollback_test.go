@@ -0,0 +1,357 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWALRollback(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	_, err := testRoleCreate(t, b, s, map[string]interface{}{
+		"org_name":        "org",
+		"developer_email": "dev@example.com",
+		"app_name":        "app",
+		"api_products":    "product",
+		"ttl":             "1h",
+	})
+
+	require.NoError(t, err)
+
+	t.Run("CommitWAL", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, fake.hasKey("org", "dev@example.com", "app", resp.Data["key"].(string)))
+
+		keys, err := framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+
+	t.Run("RollbackOrphanedKey", func(t *testing.T) {
+		client, err := b.getClient(context.Background(), s, "")
+		require.NoError(t, err)
+
+		token, err := createCredentials(context.Background(), client, "org", "dev@example.com", "app", []string{"product"}, nil, nil, 3600)
+		require.NoError(t, err)
+
+		_, err = framework.PutWAL(context.Background(), s, walTypeCredentials, &walCredentials{
+			OrgName:        "org",
+			DeveloperEmail: "dev@example.com",
+			AppName:        "app",
+			Key:            token.Key,
+		})
+		require.NoError(t, err)
+
+		_, err = framework.PutWAL(context.Background(), s, walTypeCredentials, &walCredentials{
+			OrgName:        "org",
+			DeveloperEmail: "dev@example.com",
+			AppName:        "app",
+		})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Path:      "",
+			Storage:   s,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.False(t, fake.hasKey("org", "dev@example.com", "app", token.Key))
+
+		keys, err := framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+	t.Run("RollbackOrphanedDeveloper", func(t *testing.T) {
+		client, err := b.getClient(context.Background(), s, "")
+		require.NoError(t, err)
+
+		require.NoError(t, client.CreateDeveloper(context.Background(), "org", "orphan@example.com", "Vault", "test", nil))
+
+		_, err = createApp(context.Background(), client, "org", "orphan@example.com", "orphan", []string{"product"}, nil, nil, 3600)
+		require.NoError(t, err)
+
+		_, err = framework.PutWAL(context.Background(), s, walTypeCredentials, &walCredentials{
+			Mode:           roleModeDeveloper,
+			OrgName:        "org",
+			DeveloperEmail: "orphan@example.com",
+			AppName:        "orphan",
+		})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Path:      "",
+			Storage:   s,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.False(t, fake.hasDeveloper("org", "orphan@example.com"))
+	})
+}
+
+func TestWALRollbackAppCreationFailed(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "existing")
+
+	t.Run("Rejected", func(t *testing.T) {
+		_, err := testRoleCreate(t, b, s, map[string]interface{}{
+			"org_name":          "org",
+			"developer_email":   "dev@example.com",
+			"api_products":      "product",
+			"mode":              roleModeApp,
+			"app_name_template": "existing",
+			"ttl":               "1h",
+		})
+
+		require.NoError(t, err)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.Error(t, err)
+
+		keys, err := framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Empty(t, keys)
+		require.Equal(t, []string{"existing"}, fake.apps("org", "dev@example.com"))
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		fake.makeUnavailable("CreateApp")
+
+		_, err := testRoleCreate(t, b, s, map[string]interface{}{
+			"org_name":          "org",
+			"developer_email":   "dev@example.com",
+			"api_products":      "product",
+			"mode":              roleModeApp,
+			"app_name_template": "lost",
+			"ttl":               "1h",
+		})
+
+		require.NoError(t, err)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.Error(t, err)
+
+		keys, err := framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		require.ElementsMatch(t, []string{"existing", "lost"}, fake.apps("org", "dev@example.com"))
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Path:      "",
+			Storage:   s,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.Equal(t, []string{"existing"}, fake.apps("org", "dev@example.com"))
+
+		keys, err = framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+}
+
+func TestWALRollbackDeveloperCreationFailed(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addDeveloper("org", "existing@example.com")
+
+	t.Run("Rejected", func(t *testing.T) {
+		_, err := testRoleCreate(t, b, s, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "existing@example.com",
+			"api_products":    "product",
+			"mode":            roleModeDeveloper,
+			"ttl":             "1h",
+		})
+
+		require.NoError(t, err)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.Error(t, err)
+
+		keys, err := framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Empty(t, keys)
+		require.True(t, fake.hasDeveloper("org", "existing@example.com"))
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		fake.makeUnavailable("CreateDeveloper")
+
+		_, err := testRoleCreate(t, b, s, map[string]interface{}{
+			"org_name":        "org",
+			"developer_email": "lost@example.com",
+			"api_products":    "product",
+			"mode":            roleModeDeveloper,
+			"ttl":             "1h",
+		})
+
+		require.NoError(t, err)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		require.Error(t, err)
+
+		keys, err := framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		require.True(t, fake.hasDeveloper("org", "lost@example.com"))
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Path:      "",
+			Storage:   s,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.False(t, fake.hasDeveloper("org", "lost@example.com"))
+		require.True(t, fake.hasDeveloper("org", "existing@example.com"))
+
+		keys, err = framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+}
+
+func TestWALRollbackKeyCreationFailed(t *testing.T) {
+	b, s, fake := getTestBackendWithFake(t)
+
+	fake.addOrg("org", "product")
+	fake.addApp("org", "dev@example.com", "app")
+
+	_, err := testRoleCreate(t, b, s, map[string]interface{}{
+		"org_name":        "org",
+		"developer_email": "dev@example.com",
+		"app_name":        "app",
+		"api_products":    "product",
+		"attributes":      map[string]string{"team": "payments"},
+		"ttl":             "1h",
+	})
+
+	require.NoError(t, err)
+
+	readCreds := func() error {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/test",
+			Storage:   s,
+		})
+
+		return err
+	}
+
+	rollback := func() {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RollbackOperation,
+			Path:      "",
+			Storage:   s,
+			Data:      map[string]interface{}{"immediate": true},
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	}
+
+	t.Run("UpdateAndDeleteFailed", func(t *testing.T) {
+		fake.deny("UpdateCredentials")
+		fake.deny("DeleteCredentials")
+
+		require.Error(t, readCreds())
+
+		delete(fake.denied, "UpdateCredentials")
+		delete(fake.denied, "DeleteCredentials")
+
+		keys, err := framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		require.Len(t, fake.keys("org", "dev@example.com", "app"), 1)
+
+		rollback()
+
+		require.Empty(t, fake.keys("org", "dev@example.com", "app"))
+
+		keys, err = framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+
+	t.Run("CreateRejected", func(t *testing.T) {
+		fake.deny("CreateCredentials")
+		defer delete(fake.denied, "CreateCredentials")
+
+		require.Error(t, readCreds())
+
+		keys, err := framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+
+	t.Run("CreateUnavailable", func(t *testing.T) {
+		fake.makeUnavailable("CreateCredentials")
+		defer delete(fake.unavailable, "CreateCredentials")
+
+		require.Error(t, readCreds())
+
+		keys, err := framework.ListWAL(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+	})
+}
@@ -0,0 +1,111 @@
+package secretsengine
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	templateRandomCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+type templateData struct {
+	RoleName    string
+	EntityID    string
+	EntityName  string
+	DisplayName string
+	RequestID   string
+	Time        string
+}
+
+func renderTemplate(text string, data templateData) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Funcs(template.FuncMap{
+		"random":    templateRandom,
+		"lowercase": strings.ToLower,
+		"uppercase": strings.ToUpper,
+		"truncate":  templateTruncate,
+		"replace":   templateReplace,
+		"unix_time": templateUnixTime,
+	}).Parse(text)
+
+	if err != nil {
+		return "", fmt.Errorf("error parsing template: %w", err)
+	}
+
+	var sb strings.Builder
+
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("error executing template: %w", err)
+	}
+
+	return sb.String(), nil
+}
+
+func validateTemplate(text string) error {
+	_, err := renderTemplate(text, templateData{RoleName: "role"})
+
+	return err
+}
+
+func renderTemplates(templates map[string]string, data templateData) (map[string]string, error) {
+	values := make(map[string]string, len(templates))
+
+	for name, text := range templates {
+		value, err := renderTemplate(text, data)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		values[name] = value
+	}
+
+	return values, nil
+}
+
+func templateRandom(length int) (string, error) {
+	if length <= 0 {
+		return "", fmt.Errorf("random length must be positive")
+	}
+
+	var sb strings.Builder
+
+	max := big.NewInt(int64(len(templateRandomCharset)))
+
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteByte(templateRandomCharset[n.Int64()])
+	}
+
+	return sb.String(), nil
+}
+
+func templateTruncate(maxLen int, str string) (string, error) {
+	if maxLen <= 0 {
+		return "", fmt.Errorf("truncate length must be positive")
+	}
+
+	if len(str) > maxLen {
+		return str[:maxLen], nil
+	}
+
+	return str, nil
+}
+
+func templateReplace(find string, replace string, str string) string {
+	return strings.ReplaceAll(str, find, replace)
+}
+
+func templateUnixTime() string {
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
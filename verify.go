@@ -0,0 +1,175 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// The permission probe targets a developer and app that do not exist, so
+	// Apigee answers 404 when the caller may manage keys and 401 or 403 when it
+	// may not, and nothing is ever created.
+	verifyDeveloperEmail = "vault-verify-connection@example.invalid"
+	verifyAppName        = "vault-verify-connection"
+	verifyApiProduct     = "vault-verify-connection"
+	verifyKey            = "vault-verify-connection"
+
+	verifySkippedWarning = "no organization is set by allowed_orgs or used by a role, so permission to create and delete developer app keys was not verified"
+)
+
+// verifyConnection checks that config can reach the Apigee Management API and
+// list organizations, and that it may create and delete developer app keys in
+// the organizations matching allowed_orgs or, without allowed_orgs, in those
+// used by the roles and static roles of the connection. It returns a warning
+// when there is no such organization to check.
+func (b *apigeeBackend) verifyConnection(ctx context.Context, s logical.Storage, connection string, config *apigeeConfig) ([]string, error) {
+	client, err := b.clientFunc(ctx, config)
+
+	if err != nil {
+		return nil, fmt.Errorf("error creating client: %w", err)
+	}
+
+	if client.expired() {
+		return nil, errManagementCredentialExpired
+	}
+
+	orgs, err := client.ListOrganizations(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing organizations at %s: %w", config.Host, err)
+	}
+
+	if len(config.AllowedOrgs) == 0 {
+		return b.verifyRoleOrgs(ctx, s, client, connection, config, orgs)
+	}
+
+	var probed []string
+
+	for _, org := range orgs {
+		if !strutil.StrListContainsGlob(config.AllowedOrgs, org) {
+			continue
+		}
+
+		if err := verifyOrgPermissions(ctx, client, org); err != nil {
+			return nil, err
+		}
+
+		probed = append(probed, org)
+	}
+
+	if len(probed) == 0 {
+		return nil, fmt.Errorf("no organization matching allowed_orgs is visible at %s", config.Host)
+	}
+
+	return nil, nil
+}
+
+func (b *apigeeBackend) verifyRoleOrgs(ctx context.Context, s logical.Storage, client *apigeeClient, connection string, config *apigeeConfig, orgs []string) ([]string, error) {
+	roleOrgs, err := b.connectionRoleOrgs(ctx, s, connection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roleOrgs) == 0 {
+		return []string{verifySkippedWarning}, nil
+	}
+
+	var warnings []string
+
+	for _, org := range roleOrgs {
+		if !strutil.StrListContains(orgs, org) {
+			warnings = append(warnings, fmt.Sprintf("org %q used by a role is not visible at %s", org, config.Host))
+			continue
+		}
+
+		if err := verifyOrgPermissions(ctx, client, org); err != nil {
+			return nil, err
+		}
+	}
+
+	return warnings, nil
+}
+
+// connectionRoleOrgs returns the sorted organizations used by the roles and
+// static roles of connection.
+func (b *apigeeBackend) connectionRoleOrgs(ctx context.Context, s logical.Storage, connection string) ([]string, error) {
+	seen := map[string]bool{}
+
+	names, err := s.List(ctx, "roles/")
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing roles: %w", err)
+	}
+
+	for _, name := range names {
+		role, err := b.getRole(ctx, s, name)
+
+		if err != nil {
+			return nil, fmt.Errorf("error reading role %q: %w", name, err)
+		}
+
+		if role != nil && role.Connection == connection && role.OrgName != "" {
+			seen[role.OrgName] = true
+		}
+	}
+
+	names, err = s.List(ctx, staticRoleStoragePrefix)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing static roles: %w", err)
+	}
+
+	for _, name := range names {
+		role, err := getStaticRole(ctx, s, name)
+
+		if err != nil {
+			return nil, fmt.Errorf("error reading static role %q: %w", name, err)
+		}
+
+		if role != nil && role.Connection == connection && role.OrgName != "" {
+			seen[role.OrgName] = true
+		}
+	}
+
+	orgs := make([]string, 0, len(seen))
+
+	for org := range seen {
+		orgs = append(orgs, org)
+	}
+
+	sort.Strings(orgs)
+
+	return orgs, nil
+}
+
+func verifyOrgPermissions(ctx context.Context, client *apigeeClient, orgName string) error {
+	_, err := client.CreateCredentials(ctx, orgName, verifyDeveloperEmail, verifyAppName, []string{verifyApiProduct}, nil, nil, 60)
+
+	if err := probeResult(err); err != nil {
+		return fmt.Errorf("error verifying permission to create developer app keys in org %q: %w", orgName, err)
+	}
+
+	err = client.DeleteCredentials(ctx, orgName, verifyDeveloperEmail, verifyAppName, verifyKey)
+
+	if err := probeResult(err); err != nil {
+		return fmt.Errorf("error verifying permission to delete developer app keys in org %q: %w", orgName, err)
+	}
+
+	return nil
+}
+
+func probeResult(err error) error {
+	switch {
+	case err == nil, isNotFound(err):
+		return nil
+	case isForbidden(err):
+		return fmt.Errorf("permission denied: %w", err)
+	default:
+		return err
+	}
+}